@@ -0,0 +1,442 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// programName is the name the CLI is installed and completed as
+const programName = "mscp-to-fleet-yaml"
+
+// envPrefix prefixes the environment variables bound to flags, e.g. MSCP2FLEET_OS_SCOPE
+const envPrefix = "MSCP2FLEET_"
+
+// command is a subcommand with its own flags. setup registers the flags on
+// the command's flag set and returns the function that runs it with the
+// remaining arguments once flags, environment and config have been applied.
+type command struct {
+	name     string
+	summary  string
+	args     string
+	examples []string
+	setup    func(fs *flag.FlagSet) func(args []string, summary *RunSummary) error
+}
+
+// globalOptions are the flags every command accepts, before or after its name
+type globalOptions struct {
+	config    string
+	logFormat string
+	logLevel  string
+	summary   string
+}
+
+// flagValues lists the accepted values of enumerated flags for help and completion
+var flagValues = map[string][]string{
+	"os-scope":    {mscpfleet.OSScopeNone, mscpfleet.OSScopeQuery, mscpfleet.OSScopeLabel},
+	"text-format": {mscpfleet.TextMarkdown, mscpfleet.TextPlain},
+	"posture":     {mscpfleet.PostureState, mscpfleet.PostureProfile, mscpfleet.PostureBoth},
+	"log-format":  {"text", "json"},
+	"log-level":   {"debug", "info", "warn", "error"},
+	"format":      {"text", "json"},
+}
+
+// listFlag is a flag holding a list, set with comma-separated values or by repeating the flag
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// commands returns the subcommands in the order they are listed in help
+func commands() []*command {
+	return []*command{
+		{
+			name:    "convert",
+			summary: "Convert baselines to Fleet policy files",
+			examples: []string{
+				"convert -mscp ~/macos_security -exemptions exemptions.yml -team design",
+				"convert -mscp macos_security-2.0.tar.gz -os-scope label -output fleet",
+			},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				opts := &ConvertOptions{}
+				addConvertFlags(fs, opts)
+				fs.StringVar(&opts.OutputDir, "output", "", "Output directory (default: <mscp>/fleet, or ./fleet for archives and refs)")
+				fs.BoolVar(&opts.Strict, "strict", false, "Fail on missing rules, unmapped queries and invalid policies")
+				fs.BoolVar(&opts.SplitSections, "split-sections", false, "Write one policy file per baseline section")
+				fs.StringVar(&opts.GitOpsTeamFile, "gitops-team", "", "Also write the merged policies to this Fleet GitOps team file, named after -team or the file (requires -merge)")
+				fs.StringVar(&opts.Merge, "merge", "", "Merge the baselines into one <name>-fleet-policies.yml with a single policy per rule")
+				return func(args []string, summary *RunSummary) error {
+					opts.Summary = summary
+					return RunConvert(*opts)
+				}
+			},
+		},
+		{
+			name:     "validate",
+			summary:  "Check policy files for missing fields, queries that cannot fail and duplicate names",
+			args:     "[file|dir ...]",
+			examples: []string{"validate", "validate fleet/cis_lvl1-fleet-policies.yml"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				return func(args []string, summary *RunSummary) error {
+					return RunValidate(ValidateOptions{Paths: args, Summary: summary})
+				}
+			},
+		},
+		{
+			name:     "report",
+			summary:  "Write the compliance report without writing policy files",
+			examples: []string{"report -exemptions exemptions.yml -team design -output report.md"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				opts := &ReportOptions{}
+				addConvertFlags(fs, &opts.ConvertOptions)
+				fs.StringVar(&opts.OutputFile, "output", "", "Write the report to a file instead of stdout")
+				return func(args []string, summary *RunSummary) error {
+					opts.Summary = summary
+					return RunReport(*opts)
+				}
+			},
+		},
+		{
+			name:     "sync",
+			summary:  "Update an output directory, such as a GitOps repository, and with -prune remove stale policy files",
+			examples: []string{"sync -output ~/fleet-gitops/lib/macos -dry-run"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				opts := &SyncOptions{}
+				addConvertFlags(fs, &opts.ConvertOptions)
+				fs.StringVar(&opts.OutputDir, "output", "", "Directory to sync (default: <mscp>/fleet, or ./fleet for archives and refs)")
+				fs.BoolVar(&opts.Strict, "strict", false, "Fail on missing rules, unmapped queries and invalid policies")
+				fs.BoolVar(&opts.SplitSections, "split-sections", false, "Write one policy file per baseline section")
+				fs.StringVar(&opts.GitOpsTeamFile, "gitops-team", "", "Also write the merged policies to this Fleet GitOps team file, named after -team or the file (requires -merge)")
+				fs.StringVar(&opts.Merge, "merge", "", "Merge the baselines into one <name>-fleet-policies.yml with a single policy per rule")
+				fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the changes without writing anything")
+				fs.BoolVar(&opts.Prune, "prune", false, "Remove policy files written by this tool that are no longer generated")
+				return func(args []string, summary *RunSummary) error {
+					opts.Summary = summary
+					return RunSync(*opts)
+				}
+			},
+		},
+		{
+			name:     "lint",
+			summary:  "Flag policy queries that cannot express failure",
+			examples: []string{"lint -dir fleet"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				dir := fs.String("dir", ".", "Directory holding the *-fleet-policies.yml files")
+				return func(args []string, summary *RunSummary) error {
+					return RunLint(*dir, summary)
+				}
+			},
+		},
+		{
+			name:     "diff",
+			summary:  "Compare two mSCP versions or two output directories",
+			examples: []string{"diff -old ~/macos_security -old-ref sequoia -new ~/macos_security -new-ref tahoe -format json"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				opts := &DiffOptions{}
+				fs.StringVar(&opts.Old, "old", "", "Old mSCP source or output directory")
+				fs.StringVar(&opts.OldRef, "old-ref", "", "Git ref to read when -old is a git repository")
+				fs.StringVar(&opts.New, "new", "", "New mSCP source or output directory")
+				fs.StringVar(&opts.NewRef, "new-ref", "", "Git ref to read when -new is a git repository")
+				fs.StringVar(&opts.Format, "format", "text", "Output format: text or json")
+				fs.StringVar(&opts.OutputFile, "diff-file", "", "Write the diff to a file instead of stdout")
+				return func(args []string, summary *RunSummary) error {
+					opts.Summary = summary
+					return RunDiff(*opts)
+				}
+			},
+		},
+		{
+			name:     "import",
+			summary:  "Import hand-tuned Fleet policies into the query catalog",
+			args:     "[file ...]",
+			examples: []string{"import fleet/cis_lvl1-fleet-policies.yml policies.json"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				opts := &ImportOptions{}
+				fs.StringVar(&opts.ProjectRoot, "mscp", defaultProjectRoot, "mSCP source the policies were generated from")
+				fs.StringVar(&opts.Ref, "ref", "", "Git tag, branch or commit to read from the -mscp repository")
+				fs.Var((*listFlag)(&opts.Inputs), "input", "Fleet policy files: apply YAML, GitOps YAML or API JSON (comma-separated or repeated)")
+				fs.StringVar(&opts.CatalogFile, "catalog", defaultCatalogFile, "Query catalog to update")
+				fs.BoolVar(&opts.Strict, "strict", false, "Fail when a policy matches no rule")
+				return func(args []string, summary *RunSummary) error {
+					opts.Inputs = append(opts.Inputs, args...)
+					opts.Summary = summary
+					return RunImport(*opts)
+				}
+			},
+		},
+		{
+			name:    "fix-queries",
+			summary: "Fix generic queries in existing policy files",
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				dir := fs.String("dir", ".", "Directory holding the *-fleet-policies.yml files")
+				return func(args []string, summary *RunSummary) error {
+					return RunFixQueries(*dir, summary)
+				}
+			},
+		},
+		{
+			name:    "fix-specific",
+			summary: "Fix specific query patterns based on policy names",
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				dir := fs.String("dir", ".", "Directory holding the *-fleet-policies.yml files")
+				return func(args []string, summary *RunSummary) error {
+					return RunFixSpecific(*dir, summary)
+				}
+			},
+		},
+		{
+			name:    "comprehensive",
+			summary: "Comprehensive query fixing with pattern matching",
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				dir := fs.String("dir", ".", "Directory holding the *-fleet-policies.yml files")
+				return func(args []string, summary *RunSummary) error {
+					return RunComprehensive(*dir, summary)
+				}
+			},
+		},
+		{
+			name:     "completion",
+			summary:  "Print a shell completion script for bash, zsh or fish",
+			args:     "<bash|zsh|fish>",
+			examples: []string{"completion bash > /etc/bash_completion.d/" + programName, "completion fish > ~/.config/fish/completions/" + programName + ".fish"},
+			setup: func(fs *flag.FlagSet) func([]string, *RunSummary) error {
+				return func(args []string, summary *RunSummary) error {
+					if len(args) != 1 {
+						return fmt.Errorf("%w: completion requires one shell: bash, zsh or fish", mscpfleet.ErrInput)
+					}
+					return WriteCompletion(os.Stdout, args[0])
+				}
+			},
+		},
+	}
+}
+
+// addConvertFlags registers the source and conversion flags shared by convert, report and sync
+func addConvertFlags(fs *flag.FlagSet, opts *ConvertOptions) {
+	fs.StringVar(&opts.ProjectRoot, "mscp", defaultProjectRoot, "mSCP checkout, git repository or release archive (.tar.gz, .zip)")
+	fs.StringVar(&opts.Ref, "ref", "", "Git tag, branch or commit to read from the -mscp repository")
+	fs.StringVar(&opts.ExemptionsFile, "exemptions", "", "Exemptions file listing accepted-risk rules")
+	fs.StringVar(&opts.Team, "team", "", "Team used to select team-scoped exemptions")
+	fs.Var((*listFlag)(&opts.Baselines), "baselines", "Only convert these baselines and tailorings (comma-separated or repeated)")
+	fs.Var((*listFlag)(&opts.TailoringFiles), "tailoring", "Tailoring files defining custom baselines (comma-separated or repeated)")
+	fs.StringVar(&opts.OSScope, "os-scope", mscpfleet.OSScopeQuery, "Scope policies to their macOS releases: none, query or label")
+	fs.StringVar(&opts.TextFormat, "text-format", mscpfleet.TextMarkdown, "Render descriptions and resolutions as markdown or plain text")
+	fs.StringVar(&opts.Posture, "posture", mscpfleet.PostureState, "Check SIP, Gatekeeper, firewall, FileVault and sharing service rules by device state, profile or both")
+	fs.StringVar(&opts.NameTemplate, "name-template", "", "Policy name template, e.g. 'CIS {cis_section} {title}' (default: "+mscpfleet.DefaultNameTemplate+")")
+	fs.BoolVar(&opts.SectionPrefix, "section-prefix", false, "Put the baseline section in front of the rule title in policy names")
+	fs.Var((*listFlag)(&opts.CriticalSeverities), "critical-severity", "Mark policies of rules with these severities or STIG categories critical, e.g. high or CAT I")
+	fs.Var((*listFlag)(&opts.CriticalRules), "critical-rules", "Mark the policies of these rule IDs critical (comma-separated or repeated)")
+	fs.StringVar(&opts.CatalogFile, "catalog", "", "Query catalog whose queries replace generated ones")
+	fs.IntVar(&opts.Workers, "workers", 0, "Baselines converted in parallel (default: one per CPU)")
+}
+
+// addGlobalFlags registers the flags every command accepts
+func addGlobalFlags(fs *flag.FlagSet, g *globalOptions) {
+	fs.StringVar(&g.config, "config", "", "Config file (default: "+programName+"/config.yml in the user config directory, when it exists)")
+	fs.StringVar(&g.logFormat, "log-format", "text", "Log format on stderr: text or json")
+	fs.StringVar(&g.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	fs.StringVar(&g.summary, "summary", "", "Write a JSON run summary with per-baseline counts and warnings to this file")
+}
+
+// findCommand returns the command with the given name, or nil
+func findCommand(name string) *command {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates the flag set of a command with the global flags and its own
+func newFlagSet(cmd *command, g *globalOptions, output io.Writer) (*flag.FlagSet, func([]string, *RunSummary) error) {
+	fs := flag.NewFlagSet(programName+" "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	addGlobalFlags(fs, g)
+	run := cmd.setup(fs)
+	fs.Usage = func() { printCommandUsage(fs.Output(), cmd, fs) }
+	return fs, run
+}
+
+// envName returns the environment variable bound to a flag
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// defaultConfigPath returns the per-user config file location
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, programName, "config.yml")
+}
+
+// Config holds flag values read from the config file. Top-level keys apply
+// to every command that has the flag; a mapping named after a command
+// applies to that command only and wins over top-level keys.
+type Config struct {
+	File     string
+	Global   map[string]string
+	Commands map[string]map[string]string
+}
+
+// LoadConfig reads a config file. A missing file is an error only when required.
+func LoadConfig(filename string, required bool) (*Config, error) {
+	config := &Config{File: filename, Global: map[string]string{}, Commands: map[string]map[string]string{}}
+	if filename == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("failed to read config %s: %w", filename, err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", filename, err)
+	}
+
+	known := knownFlags()
+	for key, value := range raw {
+		if section, ok := value.(map[string]interface{}); ok {
+			cmd := findCommand(key)
+			if cmd == nil {
+				return nil, fmt.Errorf("config %s: unknown command %q", filename, key)
+			}
+			values := map[string]string{}
+			for name, v := range section {
+				if !known[cmd.name][name] {
+					return nil, fmt.Errorf("config %s: command %s has no flag %q", filename, cmd.name, name)
+				}
+				values[name] = configValue(v)
+			}
+			config.Commands[cmd.name] = values
+			continue
+		}
+		if !known[""][key] {
+			return nil, fmt.Errorf("config %s: no command has a flag %q", filename, key)
+		}
+		config.Global[key] = configValue(value)
+	}
+	return config, nil
+}
+
+// configValue turns a YAML scalar or list into a flag value
+func configValue(v interface{}) string {
+	if items, ok := v.([]interface{}); ok {
+		values := make([]string, 0, len(items))
+		for _, item := range items {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// knownFlags returns the flag names of every command, keyed by command name,
+// with the union of all of them under the empty name
+func knownFlags() map[string]map[string]bool {
+	known := map[string]map[string]bool{"": {}}
+	for _, cmd := range commands() {
+		fs, _ := newFlagSet(cmd, &globalOptions{}, io.Discard)
+		known[cmd.name] = map[string]bool{}
+		fs.VisitAll(func(f *flag.Flag) {
+			known[cmd.name][f.Name] = true
+			known[""][f.Name] = true
+		})
+	}
+	return known
+}
+
+// applyDefaults fills every flag not given on the command line from its
+// environment variable, then the command's config section, then the
+// top-level config
+func applyDefaults(fs *flag.FlagSet, cmdName string, config *Config) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		source := envName(f.Name)
+		value, ok := os.LookupEnv(source)
+		if !ok {
+			source = config.File
+			value, ok = config.Commands[cmdName][f.Name]
+		}
+		if !ok {
+			value, ok = config.Global[f.Name]
+		}
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%w: invalid value %q for -%s from %s: %v", mscpfleet.ErrInput, value, f.Name, source, setErr)
+		}
+	})
+	return err
+}
+
+// printCommandUsage prints the help of a single command
+func printCommandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s\n\n", strings.TrimSpace(fmt.Sprintf("%s %s [flags] %s", programName, cmd.name, cmd.args)))
+	fmt.Fprintf(w, "%s\n\nFlags:\n", cmd.summary)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nEvery flag can also be set with an environment variable such as %s, or in the config file.\n", envName("log-level"))
+	if len(cmd.examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range cmd.examples {
+			fmt.Fprintf(w, "  %s %s\n", programName, example)
+		}
+	}
+}
+
+// printUsage prints the top-level help
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Fleet Policy Converter - Convert macOS Security Compliance Project to Fleet YAML")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  %s [global flags] <command> [flags] [args]\n", programName)
+	fmt.Fprintf(w, "  %s help <command>\n", programName)
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Global flags:")
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	addGlobalFlags(fs, &globalOptions{})
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Configuration:")
+	fmt.Fprintf(w, "  Flags are read from the command line, then %s<FLAG> environment variables\n", envPrefix)
+	fmt.Fprintln(w, "  (e.g. "+envName("os-scope")+"), then the config file, whose top-level keys apply to")
+	fmt.Fprintln(w, "  every command and whose command sections apply to that command only.")
+	if path := defaultConfigPath(); path != "" {
+		fmt.Fprintf(w, "  The default config file is %s.\n", path)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Exit codes:")
+	fmt.Fprintln(w, "  0 success, 1 other failure, 2 input error, 3 mapping gap, 4 validation failure")
+}
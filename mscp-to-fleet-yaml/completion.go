@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// completionFlag describes a flag for completion scripts
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+	values []string
+}
+
+// completionFlags returns the flags of a command, global flags included
+func completionFlags(cmd *command) []completionFlag {
+	fs, _ := newFlagSet(cmd, &globalOptions{}, io.Discard)
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:   f.Name,
+			usage:  f.Usage,
+			isBool: ok && boolFlag.IsBoolFlag(),
+			values: flagValues[f.Name],
+		})
+	})
+	return flags
+}
+
+// WriteCompletion writes the completion script for bash, zsh or fish, built
+// from the command table so it always matches the flags of this binary
+func WriteCompletion(w io.Writer, shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion()
+	case "zsh":
+		script = zshCompletion()
+	case "fish":
+		script = fishCompletion()
+	default:
+		return fmt.Errorf("%w: unsupported shell %q (use bash, zsh or fish)", mscpfleet.ErrInput, shell)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// functionName is the shell function name used by the bash and zsh scripts
+func functionName() string {
+	return "_" + strings.ReplaceAll(programName, "-", "_")
+}
+
+// commandNames returns the command names, with help, in help order
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands() {
+		names = append(names, cmd.name)
+	}
+	return append(names, "help")
+}
+
+func bashCompletion() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# bash completion for %s\n", programName)
+	fmt.Fprintf(&sb, "%s() {\n", functionName())
+	sb.WriteString("    local cur prev cmd i\n")
+	fmt.Fprintf(&sb, "    local commands=\"%s\"\n", strings.Join(commandNames(), " "))
+	sb.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	sb.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	sb.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	sb.WriteString("        if [[ \" $commands \" == *\" ${COMP_WORDS[i]} \"* ]]; then cmd=\"${COMP_WORDS[i]}\"; break; fi\n")
+	sb.WriteString("    done\n\n")
+
+	sb.WriteString("    case \"$prev\" in\n")
+	for _, name := range sortedFlagValueNames() {
+		fmt.Fprintf(&sb, "        -%s|--%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n",
+			name, name, strings.Join(flagValues[name], " "))
+	}
+	sb.WriteString("    esac\n\n")
+
+	sb.WriteString("    if [[ -z \"$cmd\" || \"$cmd\" == help ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$commands\" -- \"$cur\"))\n")
+	sb.WriteString("        return\n    fi\n\n")
+
+	sb.WriteString("    local flags\n    case \"$cmd\" in\n")
+	sb.WriteString("        completion) COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\")); return ;;\n")
+	for _, cmd := range commands() {
+		if cmd.name == "completion" {
+			continue
+		}
+		var names []string
+		for _, f := range completionFlags(cmd) {
+			names = append(names, "-"+f.name)
+		}
+		fmt.Fprintf(&sb, "        %s) flags=\"%s\" ;;\n", cmd.name, strings.Join(names, " "))
+	}
+	sb.WriteString("    esac\n\n")
+
+	sb.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	sb.WriteString("    else\n")
+	sb.WriteString("        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	sb.WriteString("    fi\n}\n")
+	fmt.Fprintf(&sb, "complete -o filenames -F %s %s\n", functionName(), programName)
+	return sb.String()
+}
+
+func zshCompletion() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#compdef %s\n\n", programName)
+	fmt.Fprintf(&sb, "%s() {\n", functionName())
+	sb.WriteString("    local -a commands\n    commands=(\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(&sb, "        '%s:%s'\n", cmd.name, zshEscape(cmd.summary))
+	}
+	sb.WriteString("        'help:Show help for a command'\n    )\n\n")
+	sb.WriteString("    if (( CURRENT == 2 )); then\n        _describe 'command' commands\n        return\n    fi\n\n")
+	sb.WriteString("    local cmd=$words[2]\n    shift words\n    (( CURRENT-- ))\n\n")
+
+	sb.WriteString("    case $cmd in\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(&sb, "        %s)\n            _arguments \\\n", cmd.name)
+		for _, f := range completionFlags(cmd) {
+			spec := fmt.Sprintf("-%s[%s]", f.name, zshEscape(f.usage))
+			switch {
+			case f.isBool:
+			case len(f.values) > 0:
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+			default:
+				spec += fmt.Sprintf(":%s:_files", f.name)
+			}
+			fmt.Fprintf(&sb, "                '%s' \\\n", spec)
+		}
+		if cmd.name == "completion" {
+			sb.WriteString("                '1:shell:(bash zsh fish)'\n            ;;\n")
+			continue
+		}
+		sb.WriteString("                '*:file:_files'\n            ;;\n")
+	}
+	sb.WriteString("        help)\n            _describe 'command' commands\n            ;;\n")
+	sb.WriteString("    esac\n}\n\n")
+	fmt.Fprintf(&sb, "%s \"$@\"\n", functionName())
+	return sb.String()
+}
+
+func fishCompletion() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# fish completion for %s\n", programName)
+	fmt.Fprintf(&sb, "complete -c %s -f\n", programName)
+	names := strings.Join(commandNames(), " ")
+	for _, cmd := range commands() {
+		fmt.Fprintf(&sb, "complete -c %s -n 'not __fish_seen_subcommand_from %s' -a %s -d '%s'\n",
+			programName, names, cmd.name, fishEscape(cmd.summary))
+	}
+	fmt.Fprintf(&sb, "complete -c %s -n 'not __fish_seen_subcommand_from %s' -a help -d 'Show help for a command'\n", programName, names)
+	fmt.Fprintf(&sb, "complete -c %s -n '__fish_seen_subcommand_from help' -a '%s'\n", programName, names)
+	fmt.Fprintf(&sb, "complete -c %s -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n", programName)
+
+	for _, cmd := range commands() {
+		for _, f := range completionFlags(cmd) {
+			line := fmt.Sprintf("complete -c %s -n '__fish_seen_subcommand_from %s' -o %s -d '%s'",
+				programName, cmd.name, f.name, fishEscape(f.usage))
+			switch {
+			case f.isBool:
+			case len(f.values) > 0:
+				line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+			default:
+				line += " -r -F"
+			}
+			sb.WriteString(line + "\n")
+		}
+		if cmd.args != "" && cmd.name != "completion" {
+			fmt.Fprintf(&sb, "complete -c %s -n '__fish_seen_subcommand_from %s' -F\n", programName, cmd.name)
+		}
+	}
+	return sb.String()
+}
+
+// sortedFlagValueNames returns the enumerated flag names in a stable order
+func sortedFlagValueNames() []string {
+	names := make([]string, 0, len(flagValues))
+	for name := range flagValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// zshEscape escapes text for a single-quoted _arguments spec or _describe entry
+func zshEscape(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// fishEscape escapes text for a single-quoted fish string
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+}
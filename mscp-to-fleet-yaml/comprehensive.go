@@ -2,27 +2,26 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"mscp-to-fleet-yaml/mscpfleet"
 )
 
 // ComprehensiveQueryFixer handles comprehensive query fixing
-type ComprehensiveQueryFixer struct {
-	queryMappings []QueryMapping
-}
+type ComprehensiveQueryFixer struct{}
 
 // NewComprehensiveQueryFixer creates a new comprehensive query fixer
 func NewComprehensiveQueryFixer() *ComprehensiveQueryFixer {
-	return &ComprehensiveQueryFixer{
-		queryMappings: CreateQueryMappings(),
-	}
+	return &ComprehensiveQueryFixer{}
 }
 
 // FixPolicyQueries fixes queries in a single policy file
 func (cqf *ComprehensiveQueryFixer) FixPolicyQueries(filePath string) (int, error) {
-	fmt.Printf("Processing %s...\n", filePath)
+	slog.Info("processing file", "file", filePath)
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -49,39 +48,31 @@ func (cqf *ComprehensiveQueryFixer) FixPolicyQueries(filePath string) (int, erro
 			return match
 		}
 
-		// Find matching pattern
-		for _, mapping := range cqf.queryMappings {
-			pattern, err := regexp.Compile("(?i)" + mapping.Pattern)
-			if err != nil {
-				continue
-			}
-			if pattern.MatchString(policyName) {
-				changesMade++
-				return fmt.Sprintf("%s%s%s", submatches[1], mapping.Query, submatches[4])
-			}
+		// Find matching pattern; names no mapping covers are left for manual review
+		query, ok := mscpfleet.QueryForPolicyName(policyName)
+		if !ok {
+			return match
 		}
-
-		// Default fallback
 		changesMade++
-		return fmt.Sprintf("%s%s%s", submatches[1], cqf.queryMappings[len(cqf.queryMappings)-1].Query, submatches[4])
+		return submatches[1] + yamlScalar(query) + submatches[4]
 	})
 
 	if changesMade > 0 {
-		err = os.WriteFile(filePath, []byte(newContent), 0644)
+		err = mscpfleet.WriteFileAtomic(filePath, []byte(newContent))
 		if err != nil {
 			return 0, fmt.Errorf("failed to write file %s: %w", filePath, err)
 		}
-		fmt.Printf("  Fixed %d queries\n", changesMade)
+		slog.Info("fixed queries", "file", filePath, "changes", changesMade)
 	} else {
-		fmt.Printf("  No queries needed fixing\n")
+		slog.Info("no queries needed fixing", "file", filePath)
 	}
 
 	return changesMade, nil
 }
 
-// ProcessAllFiles processes all YAML files in the current directory
-func (cqf *ComprehensiveQueryFixer) ProcessAllFiles() error {
-	yamlFiles, err := filepath.Glob("*-fleet-policies.yml")
+// ProcessAllFiles processes all policy files in dir
+func (cqf *ComprehensiveQueryFixer) ProcessAllFiles(dir string, summary *RunSummary) error {
+	yamlFiles, err := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
 	if err != nil {
 		return fmt.Errorf("failed to find YAML files: %w", err)
 	}
@@ -95,19 +86,20 @@ func (cqf *ComprehensiveQueryFixer) ProcessAllFiles() error {
 
 		changes, err := cqf.FixPolicyQueries(yamlFile)
 		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", yamlFile, err)
+			summary.Warn(mscpfleet.Warning{Message: err.Error(), File: yamlFile})
 			continue
 		}
+		summary.Count("files", 1)
 		totalChanges += changes
 	}
 
-	fmt.Printf("\nTotal queries fixed: %d\n", totalChanges)
-	fmt.Println("\nAll policy files have been updated with appropriate queries!")
+	summary.Count("changes", totalChanges)
+	slog.Info("comprehensive query fixing complete", "changes", totalChanges)
 	return nil
 }
 
 // RunComprehensive runs the comprehensive query fixer
-func RunComprehensive() error {
+func RunComprehensive(dir string, summary *RunSummary) error {
 	fixer := NewComprehensiveQueryFixer()
-	return fixer.ProcessAllFiles()
+	return fixer.ProcessAllFiles(dir, summary)
 }
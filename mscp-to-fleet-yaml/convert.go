@@ -2,163 +2,202 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
+
+	"mscp-to-fleet-yaml/mscpfleet"
 )
 
-// BaselineConverter handles conversion of baselines to Fleet format
-type BaselineConverter struct {
-	projectRoot  string
-	baselinesDir string
-	rulesDir     string
-	outputDir    string
+// defaultProjectRoot is the default location of the macOS Security Compliance
+// Project checkout: the current directory
+const defaultProjectRoot = "."
+
+// ConvertOptions holds the options for the convert command
+type ConvertOptions struct {
+	ProjectRoot    string
+	Ref            string
+	OutputDir      string
+	ExemptionsFile string
+	Team           string
+	TailoringFiles []string
+	Baselines      []string
+	Merge          string
+	OSScope        string
+	TextFormat     string
+	Posture        string
+	SectionPrefix  bool
+	NameTemplate   string
+	// CriticalSeverities and CriticalRules select the policies marked critical
+	CriticalSeverities []string
+	CriticalRules      []string
+	SplitSections      bool
+	GitOpsTeamFile     string
+	CatalogFile        string
+	Summary            *RunSummary
+	Strict             bool
+	Workers            int
 }
 
-// NewBaselineConverter creates a new baseline converter
-func NewBaselineConverter(projectRoot string) *BaselineConverter {
-	return &BaselineConverter{
-		projectRoot:  projectRoot,
-		baselinesDir: filepath.Join(projectRoot, "baselines"),
-		rulesDir:     filepath.Join(projectRoot, "rules"),
-		outputDir:    filepath.Join(projectRoot, "fleet"),
+// RunConvert runs the baseline conversion and writes the policies, labels
+// and compliance report
+func RunConvert(opts ConvertOptions) error {
+	converter, source, err := newConverter(&opts)
+	if err != nil {
+		return err
 	}
-}
+	outputDir := defaultOutputDir(source, opts.OutputDir)
 
-// LoadRule loads a rule definition from the rules directory
-func (bc *BaselineConverter) LoadRule(ruleID string) (*Rule, error) {
-	// Try different possible locations for the rule
-	possiblePaths := []string{
-		filepath.Join(bc.rulesDir, "os", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "system_settings", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "audit", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "auth", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "icloud", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "pwpolicy", ruleID+".yaml"),
-		filepath.Join(bc.rulesDir, "supplemental", ruleID+".yaml"),
-	}
-
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			var rule Rule
-			err := LoadYAML(path, &rule)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load rule %s from %s: %w", ruleID, path, err)
-			}
-			return &rule, nil
+	result, err := convertAll(converter, &opts)
+	if err != nil {
+		return err
+	}
+	// A strict run that fails leaves the existing output untouched
+	if opts.Strict {
+		if err := converter.StrictCheck(); err != nil {
+			return err
 		}
 	}
+	if err := mscpfleet.WriteOutput(outputDir, result, opts.writeOptions()); err != nil {
+		return err
+	}
 
-	fmt.Printf("Warning: Could not find rule %s\n", ruleID)
-	return nil, nil
+	slog.Info("conversion complete", "policies", len(result.Policies()), "baselines", len(result.Baselines),
+		"output", outputDir, "report", filepath.Join(outputDir, "compliance-report.md"))
+	return nil
 }
 
-// ConvertBaselineToFleet converts a baseline to Fleet-compatible YAML
-func (bc *BaselineConverter) ConvertBaselineToFleet(baselinePath string) (int, error) {
-	var baseline Baseline
-	err := LoadYAML(baselinePath, &baseline)
-	if err != nil {
-		return 0, fmt.Errorf("failed to load baseline %s: %w", baselinePath, err)
-	}
-
-	baselineName := GetBaselineName(baselinePath)
-	outputFile := filepath.Join(bc.outputDir, baselineName+"-fleet-policies.yml")
-
-	policies := []*FleetPolicy{}
-
-	// Process each section and its rules
-	for _, section := range baseline.Profile {
-		rules := section.Rules
-
-		for _, ruleID := range rules {
-			rule, err := bc.LoadRule(ruleID)
-			if err != nil {
-				fmt.Printf("Error loading rule %s: %v\n", ruleID, err)
-				continue
-			}
-			if rule != nil {
-				policy := CreateFleetPolicy(rule, baselineName)
-				if policy != nil {
-					policies = append(policies, policy)
-				}
-			}
-		}
+// newConverter opens the mSCP source and configures a converter from the
+// convert options shared by convert, report and sync
+func newConverter(opts *ConvertOptions) (*mscpfleet.BaselineConverter, *mscpfleet.Source, error) {
+	projectRoot := opts.ProjectRoot
+	if projectRoot == "" {
+		projectRoot = defaultProjectRoot
+	}
+	if opts.Summary == nil {
+		opts.Summary = NewRunSummary("convert")
+	}
+	// Baselines share most rules, so only merged policies have unique names
+	if opts.GitOpsTeamFile != "" && opts.Merge == "" {
+		return nil, nil, fmt.Errorf("%w: -gitops-team requires -merge, since a team file cannot hold the same policy name twice", mscpfleet.ErrInput)
 	}
 
-	// Write all policies to output file
-	err = os.MkdirAll(bc.outputDir, 0755)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create output directory: %w", err)
+	// Check if the project root exists
+	if _, err := os.Stat(projectRoot); os.IsNotExist(err) {
+		slog.Error("project root does not exist, pass -mscp with the path to your checkout or release archive", "path", projectRoot)
+		return nil, nil, fmt.Errorf("%w: project root not found: %s", mscpfleet.ErrInput, projectRoot)
 	}
 
-	file, err := os.Create(outputFile)
+	source, err := mscpfleet.OpenSource(projectRoot, opts.Ref)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create output file %s: %w", outputFile, err)
+		return nil, nil, mscpfleet.InputError(err)
 	}
-	defer file.Close()
-
-	// Write header
-	title := baseline.Title
-	if title == "" {
-		title = baselineName
+	slog.Info("reading mSCP", "version", source.Version, "location", source.Location)
+	opts.Summary.Source = source.Version
+
+	converter := mscpfleet.NewBaselineConverter(source)
+	converter.SetDiagnostics(opts.Summary.Diagnostics)
+	if opts.OSScope != "" {
+		if err := converter.SetOSScope(opts.OSScope); err != nil {
+			return nil, nil, mscpfleet.InputError(err)
+		}
 	}
-	fmt.Fprintf(file, "# Fleet policies for %s\n", title)
-	fmt.Fprintf(file, "# Generated from macOS Security Compliance Project\n\n")
-
-	// Write policies
-	for i, policy := range policies {
-		data, err := MarshalYAML(policy)
-		if err != nil {
-			fmt.Printf("Error marshaling policy %d: %v\n", i, err)
-			continue
+	if opts.TextFormat != "" {
+		if err := converter.SetTextFormat(opts.TextFormat); err != nil {
+			return nil, nil, mscpfleet.InputError(err)
 		}
-		file.Write(data)
-		file.WriteString("---\n")
 	}
-
-	fmt.Printf("Converted %s: %d policies written to %s\n", baselineName, len(policies), outputFile)
-	return len(policies), nil
-}
-
-// ConvertAllBaselines converts all baseline files
-func (bc *BaselineConverter) ConvertAllBaselines() error {
-	// Ensure output directory exists
-	err := os.MkdirAll(bc.outputDir, 0755)
+	if opts.Posture != "" {
+		if err := converter.SetPosture(opts.Posture); err != nil {
+			return nil, nil, mscpfleet.InputError(err)
+		}
+	}
+	converter.SetSectionPrefix(opts.SectionPrefix)
+	if opts.NameTemplate != "" {
+		if opts.SectionPrefix {
+			return nil, nil, fmt.Errorf("%w: -section-prefix cannot be combined with -name-template; use {section} in the template", mscpfleet.ErrInput)
+		}
+		if err := converter.SetNameTemplate(opts.NameTemplate); err != nil {
+			return nil, nil, mscpfleet.InputError(err)
+		}
+	}
+	critical, err := mscpfleet.NewCriticalRules(opts.CriticalSeverities, opts.CriticalRules)
 	if err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return nil, nil, mscpfleet.InputError(err)
+	}
+	converter.SetCriticalRules(critical)
+	converter.SetBaselines(opts.Baselines)
+	converter.SetStrict(opts.Strict)
+	converter.SetWorkers(opts.Workers)
+	if opts.Strict {
+		slog.Info("strict mode: missing rules, unmapped queries and invalid policies fail the run")
 	}
 
-	// Find all baseline files
-	baselineFiles, err := filepath.Glob(filepath.Join(bc.baselinesDir, "*.yaml"))
-	if err != nil {
-		return fmt.Errorf("failed to find baseline files: %w", err)
+	if opts.ExemptionsFile != "" {
+		exemptions, err := mscpfleet.LoadExemptions(opts.ExemptionsFile, time.Now())
+		if err != nil {
+			return nil, nil, mscpfleet.InputError(err)
+		}
+		converter.SetExemptions(exemptions, opts.Team)
 	}
 
-	totalPolicies := 0
-	for _, baselineFile := range baselineFiles {
-		count, err := bc.ConvertBaselineToFleet(baselineFile)
+	if opts.CatalogFile != "" {
+		catalog, err := mscpfleet.LoadQueryCatalog(opts.CatalogFile)
 		if err != nil {
-			fmt.Printf("Error converting %s: %v\n", baselineFile, err)
-			continue
+			return nil, nil, mscpfleet.InputError(err)
 		}
-		totalPolicies += count
+		slog.Info("using query catalog", "file", opts.CatalogFile, "queries", len(catalog.Queries))
+		converter.SetCatalog(catalog)
 	}
 
-	fmt.Printf("\nConversion complete! Generated %d total policies across %d baselines.\n", totalPolicies, len(baselineFiles))
-	fmt.Printf("Output directory: %s\n", bc.outputDir)
-	return nil
+	for _, tailoringFile := range opts.TailoringFiles {
+		tailoring, err := mscpfleet.LoadTailoring(tailoringFile)
+		if err != nil {
+			return nil, nil, mscpfleet.InputError(err)
+		}
+		converter.AddTailoring(tailoring)
+	}
+	return converter, source, nil
 }
 
-// RunConvert runs the baseline conversion
-func RunConvert() error {
-	// Set up paths - you may need to adjust this path
-	projectRoot := "/Users/allen/GitHub/macos_security"
+// convertAll converts the selected baselines and, with -merge, merges them
+// into one baseline with a single policy per rule
+func convertAll(converter *mscpfleet.BaselineConverter, opts *ConvertOptions) (*mscpfleet.Result, error) {
+	result, err := converter.ConvertAllBaselines()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Merge == "" {
+		return result, nil
+	}
+	before := len(result.Policies())
+	if err := result.Merge(opts.Merge); err != nil {
+		return nil, err
+	}
+	merged := len(result.Policies())
+	opts.Summary.Count("merged_policies", merged)
+	slog.Info("merged baselines", "name", opts.Merge, "policies", before, "merged_policies", merged)
+	return result, nil
+}
 
-	// Check if the project root exists
-	if _, err := os.Stat(projectRoot); os.IsNotExist(err) {
-		fmt.Printf("Project root %s does not exist. Please update the path in convert.go\n", projectRoot)
-		return fmt.Errorf("project root not found: %s", projectRoot)
+// writeOptions returns how the policy files are laid out
+func (opts *ConvertOptions) writeOptions() mscpfleet.WriteOptions {
+	return mscpfleet.WriteOptions{
+		SplitSections:  opts.SplitSections,
+		GitOpsTeamFile: opts.GitOpsTeamFile,
+		GitOpsTeam:     opts.Team,
 	}
+}
 
-	converter := NewBaselineConverter(projectRoot)
-	return converter.ConvertAllBaselines()
+// defaultOutputDir returns outputDir, or the fleet directory of a local
+// checkout, or ./fleet for archives and git refs
+func defaultOutputDir(source *mscpfleet.Source, outputDir string) string {
+	if outputDir != "" {
+		return outputDir
+	}
+	if source.Dir != "" {
+		return filepath.Join(source.Dir, "fleet")
+	}
+	return "fleet"
 }
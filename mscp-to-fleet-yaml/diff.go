@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// DiffOptions holds the options for the diff command
+type DiffOptions struct {
+	Old        string
+	OldRef     string
+	New        string
+	NewRef     string
+	Format     string
+	OutputFile string
+	Summary    *RunSummary
+}
+
+// RunDiff compares two mSCP sources or two output directories
+func RunDiff(opts DiffOptions) error {
+	if opts.Old == "" || opts.New == "" {
+		return fmt.Errorf("%w: diff requires -old and -new", mscpfleet.ErrInput)
+	}
+	if opts.Summary == nil {
+		opts.Summary = NewRunSummary("diff")
+	}
+
+	oldSide, err := mscpfleet.LoadDiffSide(opts.Old, opts.OldRef)
+	if err != nil {
+		return mscpfleet.InputError(fmt.Errorf("failed to load old side: %w", err))
+	}
+	newSide, err := mscpfleet.LoadDiffSide(opts.New, opts.NewRef)
+	if err != nil {
+		return mscpfleet.InputError(fmt.Errorf("failed to load new side: %w", err))
+	}
+
+	report := mscpfleet.DiffSides(oldSide, newSide)
+	opts.Summary.Source = fmt.Sprintf("%s -> %s", report.Old, report.New)
+	for _, baseline := range report.Baselines {
+		opts.Summary.Count("added_rules", len(baseline.AddedRules))
+		opts.Summary.Count("removed_rules", len(baseline.RemovedRules))
+		opts.Summary.Count("reworded_rules", len(baseline.RewordedRules))
+		opts.Summary.Count("changed_queries", len(baseline.ChangedQueries))
+		opts.Summary.Count("changed_references", len(baseline.ChangedReferences))
+		opts.Summary.Count("changed_tags", len(baseline.ChangedTags))
+		opts.Summary.Count("changed_odvs", len(baseline.ChangedODVs))
+	}
+
+	var output []byte
+	switch opts.Format {
+	case "", "text":
+		output = []byte(report.Text())
+	case "json":
+		output, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff: %w", err)
+		}
+		output = append(output, '\n')
+	default:
+		return fmt.Errorf("%w: unknown diff format %q (use text or json)", mscpfleet.ErrInput, opts.Format)
+	}
+
+	if opts.OutputFile == "" {
+		_, err = os.Stdout.Write(output)
+		return err
+	}
+	if err := mscpfleet.WriteFileAtomic(opts.OutputFile, output); err != nil {
+		return fmt.Errorf("failed to write diff %s: %w", opts.OutputFile, err)
+	}
+	slog.Info("diff written", "file", opts.OutputFile, "baselines", len(report.Baselines))
+	return nil
+}
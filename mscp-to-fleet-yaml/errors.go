@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// Exit codes reported by the command line tool
+const (
+	ExitFailure    = 1
+	ExitInput      = 2
+	ExitMapping    = 3
+	ExitValidation = 4
+)
+
+// ExitCode returns the process exit code for the error a command returned
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, mscpfleet.ErrInput), errors.Is(err, mscpfleet.ErrRuleNotFound):
+		return ExitInput
+	case errors.Is(err, mscpfleet.ErrMapping):
+		return ExitMapping
+	case errors.Is(err, mscpfleet.ErrValidation):
+		return ExitValidation
+	}
+	return ExitFailure
+}
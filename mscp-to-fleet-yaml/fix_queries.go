@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"mscp-to-fleet-yaml/mscpfleet"
 )
 
 // QueryFixer handles fixing generic queries in YAML files
@@ -18,7 +21,7 @@ func NewQueryFixer() *QueryFixer {
 
 // FixGenericQueries fixes generic queries in a YAML file
 func (qf *QueryFixer) FixGenericQueries(filePath string) (int, error) {
-	fmt.Printf("Processing %s...\n", filePath)
+	slog.Info("processing file", "file", filePath)
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -51,21 +54,21 @@ func (qf *QueryFixer) FixGenericQueries(filePath string) (int, error) {
 	})
 
 	if changes > 0 {
-		err = os.WriteFile(filePath, []byte(originalContent), 0644)
+		err = mscpfleet.WriteFileAtomic(filePath, []byte(originalContent))
 		if err != nil {
 			return 0, fmt.Errorf("failed to write file %s: %w", filePath, err)
 		}
-		fmt.Printf("  Added %d TODO comments for generic queries\n", changes)
+		slog.Info("added TODO comments for generic queries", "file", filePath, "changes", changes)
 	} else {
-		fmt.Printf("  No generic queries found to fix\n")
+		slog.Info("no generic queries found to fix", "file", filePath)
 	}
 
 	return changes, nil
 }
 
-// ProcessAllFiles processes all YAML files in the current directory
-func (qf *QueryFixer) ProcessAllFiles() error {
-	yamlFiles, err := filepath.Glob("*-fleet-policies.yml")
+// ProcessAllFiles processes all policy files in dir
+func (qf *QueryFixer) ProcessAllFiles(dir string, summary *RunSummary) error {
+	yamlFiles, err := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
 	if err != nil {
 		return fmt.Errorf("failed to find YAML files: %w", err)
 	}
@@ -79,22 +82,21 @@ func (qf *QueryFixer) ProcessAllFiles() error {
 
 		changes, err := qf.FixGenericQueries(yamlFile)
 		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", yamlFile, err)
+			summary.Warn(mscpfleet.Warning{Message: err.Error(), File: yamlFile})
 			continue
 		}
+		summary.Count("files", 1)
 		totalChanges += changes
 	}
 
-	fmt.Printf("\nTotal changes made: %d\n", totalChanges)
-	fmt.Println("\nNext steps:")
-	fmt.Println("1. Review the TODO comments in each file")
-	fmt.Println("2. Replace TODO comments with specific queries based on policy requirements")
-	fmt.Println("3. Test the corrected queries")
+	summary.Count("changes", totalChanges)
+	slog.Info("query fixing complete", "changes", totalChanges,
+		"next_steps", "review the TODO comments, replace them with specific queries and test the corrected queries")
 	return nil
 }
 
 // RunFixQueries runs the query fixer
-func RunFixQueries() error {
+func RunFixQueries(dir string, summary *RunSummary) error {
 	fixer := NewQueryFixer()
-	return fixer.ProcessAllFiles()
+	return fixer.ProcessAllFiles(dir, summary)
 }
@@ -2,10 +2,15 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
+	"mscp-to-fleet-yaml/mscpfleet"
 )
 
 // SpecificQueryFixer handles fixing specific query patterns
@@ -16,52 +21,79 @@ func NewSpecificQueryFixer() *SpecificQueryFixer {
 	return &SpecificQueryFixer{}
 }
 
-// FixAuditQueries fixes audit-related queries
-func (sqf *SpecificQueryFixer) FixAuditQueries(content string) string {
-	// Audit log files ACL check
-	auditACLPattern := regexp.MustCompile(`(\s+query: SELECT 1;)(\s+# TODO: Replace with specific query for this policy)`)
-	content = auditACLPattern.ReplaceAllString(content, "$1 FROM file WHERE (path LIKE '/var/audit/%' OR path LIKE '/etc/security/%') AND extended_attributes LIKE '%com.apple.acl%';")
-
-	// Audit folder ACL check
-	auditFolderPattern := regexp.MustCompile(`(\s+query: SELECT 1 FROM file WHERE path LIKE '/[^%]+%';)(\s+# TODO: Replace with specific file validation query)`)
-	content = auditFolderPattern.ReplaceAllString(content, "$1 AND type = 'directory' AND extended_attributes LIKE '%com.apple.acl%';")
-
-	// Security auditing enabled
-	auditServicePattern := regexp.MustCompile(`(\s+query: SELECT 1 FROM launchd WHERE name LIKE '%audit%';)(\s+# TODO: Replace with specific service validation query)`)
-	content = auditServicePattern.ReplaceAllString(content, "$1 AND state = 'running';")
+var (
+	todoQueryPattern  = regexp.MustCompile(`^(\s+query: )SELECT 1(?: FROM \w+ WHERE [^#]*)?;\s+# TODO: Replace with specific .*$`)
+	policyNamePattern = regexp.MustCompile(`^\s+name: (.+)$`)
+	auditPolicyNames  = regexp.MustCompile(`(?i)enable.*security.*auditing`)
+	filePolicyNames   = regexp.MustCompile(`(?i)audit.*(log.*files|folders)`)
+)
 
-	return content
+// FixAuditQueries fixes audit-related queries. No osquery table reads ACLs,
+// so the audit ACL policies have no fix.
+func (sqf *SpecificQueryFixer) FixAuditQueries(content string) string {
+	return fixTODOQueries(content, auditPolicyNames.MatchString)
 }
 
-// FixFilePermissionQueries fixes file permission related queries
+// FixFilePermissionQueries fixes audit log file and folder ownership and
+// mode queries
 func (sqf *SpecificQueryFixer) FixFilePermissionQueries(content string) string {
-	// File ownership by root
-	fileOwnershipPattern := regexp.MustCompile(`(\s+query: SELECT 1;)(\s+# TODO: Replace with specific query for this policy)`)
-	content = fileOwnershipPattern.ReplaceAllString(content, "$1 FROM file WHERE path LIKE '/var/audit/%' AND uid = 0;")
-
-	// File group ownership
-	fileGroupPattern := regexp.MustCompile(`(\s+query: SELECT 1;)(\s+# TODO: Replace with specific query for this policy)`)
-	content = fileGroupPattern.ReplaceAllString(content, "$1 FROM file WHERE path LIKE '/var/audit/%' AND gid = 0;")
-
-	// File permissions
-	filePermissionsPattern := regexp.MustCompile(`(\s+query: SELECT 1;)(\s+# TODO: Replace with specific query for this policy)`)
-	content = filePermissionsPattern.ReplaceAllString(content, "$1 FROM file WHERE path LIKE '/var/audit/%' AND mode <= '440';")
-
-	return content
+	return fixTODOQueries(content, filePolicyNames.MatchString)
 }
 
-// FixManagedPolicyQueries fixes managed policy queries
+// FixManagedPolicyQueries fixes queries whose policy maps to a managed
+// profile setting. Each mapping checks the setting's name and value; policies
+// with no mapping keep their TODO for manual review.
 func (sqf *SpecificQueryFixer) FixManagedPolicyQueries(content string) string {
-	// Generic managed policy check
-	managedPolicyPattern := regexp.MustCompile(`(\s+query: SELECT 1;)(\s+# TODO: Replace with specific query for this policy)`)
-	content = managedPolicyPattern.ReplaceAllString(content, "$1 FROM managed_policies WHERE domain = 'com.apple.applicationaccess';")
+	return fixTODOQueries(content, func(name string) bool {
+		query, ok := mscpfleet.QueryForPolicyName(name)
+		return ok && strings.Contains(query, "managed_policies")
+	})
+}
+
+// fixTODOQueries replaces each TODO query of a policy whose name matches
+// with the query the comprehensive fixer maps that name to. Policies the
+// mappings leave for manual review keep their TODO.
+func fixTODOQueries(content string, match func(name string) bool) string {
+	lines := strings.Split(content, "\n")
+	name := ""
+	for i, line := range lines {
+		if line == "---" {
+			name = ""
+			continue
+		}
+		if m := policyNamePattern.FindStringSubmatch(line); m != nil {
+			if err := yaml.Unmarshal([]byte(m[1]), &name); err != nil {
+				name = m[1]
+			}
+			continue
+		}
+		m := todoQueryPattern.FindStringSubmatch(line)
+		if m == nil || name == "" || !match(name) {
+			continue
+		}
+		if query, ok := mscpfleet.QueryForPolicyName(name); ok {
+			lines[i] = m[1] + yamlScalar(query)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
 
-	return content
+// yamlScalar renders value as a single-line YAML scalar
+func yamlScalar(value string) string {
+	node := yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if strings.ContainsAny(value, "\n\r") {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return strconv.Quote(value)
+	}
+	return strings.TrimSuffix(string(out), "\n")
 }
 
 // FixSpecificPolicyQueries fixes specific policy queries based on policy names
 func (sqf *SpecificQueryFixer) FixSpecificPolicyQueries(filePath string) (int, error) {
-	fmt.Printf("Processing %s...\n", filePath)
+	slog.Info("processing file", "file", filePath)
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -84,20 +116,20 @@ func (sqf *SpecificQueryFixer) FixSpecificPolicyQueries(filePath string) (int, e
 	remainingTodos := strings.Count(contentStr, "# TODO:")
 
 	if contentStr != originalContent {
-		err = os.WriteFile(filePath, []byte(contentStr), 0644)
+		err = mscpfleet.WriteFileAtomic(filePath, []byte(contentStr))
 		if err != nil {
 			return 0, fmt.Errorf("failed to write file %s: %w", filePath, err)
 		}
-		fmt.Printf("  Applied specific query fixes\n")
+		slog.Info("applied specific query fixes", "file", filePath)
 	}
 
-	fmt.Printf("  Remaining TODO comments: %d\n", remainingTodos)
+	slog.Info("remaining TODO comments", "file", filePath, "remaining", remainingTodos)
 	return remainingTodos, nil
 }
 
-// ProcessAllFiles processes all YAML files in the current directory
-func (sqf *SpecificQueryFixer) ProcessAllFiles() error {
-	yamlFiles, err := filepath.Glob("*-fleet-policies.yml")
+// ProcessAllFiles processes all policy files in dir
+func (sqf *SpecificQueryFixer) ProcessAllFiles(dir string, summary *RunSummary) error {
+	yamlFiles, err := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
 	if err != nil {
 		return fmt.Errorf("failed to find YAML files: %w", err)
 	}
@@ -111,19 +143,23 @@ func (sqf *SpecificQueryFixer) ProcessAllFiles() error {
 
 		remaining, err := sqf.FixSpecificPolicyQueries(yamlFile)
 		if err != nil {
-			fmt.Printf("Error processing %s: %v\n", yamlFile, err)
+			summary.Warn(mscpfleet.Warning{Message: err.Error(), File: yamlFile})
 			continue
 		}
+		summary.Count("files", 1)
 		totalRemaining += remaining
 	}
 
-	fmt.Printf("\nTotal remaining TODO comments: %d\n", totalRemaining)
-	fmt.Println("\nNote: Some policies may require manual review to create appropriate queries.")
+	summary.Count("remaining_todos", totalRemaining)
+	slog.Info("specific query fixing complete", "remaining_todos", totalRemaining)
+	if totalRemaining > 0 {
+		slog.Warn("some policies need manual review to create appropriate queries", "remaining_todos", totalRemaining)
+	}
 	return nil
 }
 
 // RunFixSpecific runs the specific query fixer
-func RunFixSpecific() error {
+func RunFixSpecific(dir string, summary *RunSummary) error {
 	fixer := NewSpecificQueryFixer()
-	return fixer.ProcessAllFiles()
+	return fixer.ProcessAllFiles(dir, summary)
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// defaultCatalogFile is the query catalog written by import when none is given
+const defaultCatalogFile = "query-catalog.yml"
+
+// ImportOptions holds the options for the import command
+type ImportOptions struct {
+	Inputs      []string
+	CatalogFile string
+	ProjectRoot string
+	Ref         string
+	Summary     *RunSummary
+	Strict      bool
+}
+
+// RunImport imports hand-tuned Fleet policies into the query catalog
+func RunImport(opts ImportOptions) error {
+	if len(opts.Inputs) == 0 {
+		return fmt.Errorf("%w: import requires -input with one or more policy files", mscpfleet.ErrInput)
+	}
+	catalogFile := opts.CatalogFile
+	if catalogFile == "" {
+		catalogFile = defaultCatalogFile
+	}
+	projectRoot := opts.ProjectRoot
+	if projectRoot == "" {
+		projectRoot = defaultProjectRoot
+	}
+	if opts.Summary == nil {
+		opts.Summary = NewRunSummary("import")
+	}
+
+	source, err := mscpfleet.OpenSource(projectRoot, opts.Ref)
+	if err != nil {
+		return mscpfleet.InputError(err)
+	}
+	opts.Summary.Source = source.Version
+	matcher, err := mscpfleet.NewRuleMatcher(source)
+	if err != nil {
+		return mscpfleet.InputError(err)
+	}
+	catalog, err := mscpfleet.LoadQueryCatalog(catalogFile)
+	if err != nil {
+		return mscpfleet.InputError(err)
+	}
+
+	imported := time.Now().Format(mscpfleet.DateLayout)
+	written, unchanged, unmatched := 0, 0, 0
+	for _, input := range opts.Inputs {
+		policies, err := mscpfleet.LoadImportedPolicies(input)
+		if err != nil {
+			return mscpfleet.InputError(err)
+		}
+		slog.Info("processing file", "file", input, "policies", len(policies))
+
+		for _, policy := range policies {
+			if strings.TrimSpace(policy.Query) == "" {
+				continue
+			}
+			rule, err := matcher.Match(policy.Name)
+			if err != nil {
+				opts.Summary.Warn(mscpfleet.Warning{Message: err.Error(), Policy: policy.Name, File: input})
+				unmatched++
+				continue
+			}
+			if rule == nil {
+				opts.Summary.Warn(mscpfleet.Warning{Message: "no rule matches policy", Policy: policy.Name, File: input})
+				unmatched++
+				continue
+			}
+
+			// Only queries that differ from what the converter would generate
+			// (or from the catalog already) are worth recording
+			if !catalog.Import(rule, policy.Query, mscpfleet.CatalogEntry{
+				Policy:   policy.Name,
+				Source:   filepath.Base(input),
+				Imported: imported,
+			}) {
+				unchanged++
+				continue
+			}
+			slog.Info("imported query", "rule_id", rule.ID, "policy", policy.Name, "file", input)
+			written++
+		}
+	}
+
+	opts.Summary.Count("imported", written)
+	opts.Summary.Count("unchanged", unchanged)
+	opts.Summary.Count("unmatched", unmatched)
+	// A strict run that fails leaves the catalog untouched
+	if opts.Strict && unmatched > 0 {
+		return fmt.Errorf("%w: %d policies match no rule", mscpfleet.ErrMapping, unmatched)
+	}
+
+	if written > 0 {
+		if err := catalog.Save(catalogFile); err != nil {
+			return fmt.Errorf("failed to write query catalog %s: %w", catalogFile, err)
+		}
+	}
+	slog.Info("import complete", "catalog", catalogFile, "imported", written, "unchanged", unchanged, "unmatched", unmatched)
+	return nil
+}
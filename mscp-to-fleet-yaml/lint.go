@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// RunLint lints the queries in all policy files in dir
+func RunLint(dir string, summary *RunSummary) error {
+	yamlFiles, err := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
+	if err != nil {
+		return fmt.Errorf("failed to find YAML files: %w", err)
+	}
+
+	totalIssues := 0
+	for _, yamlFile := range yamlFiles {
+		issues, err := mscpfleet.LintPolicyFile(yamlFile)
+		if err != nil {
+			summary.Warn(mscpfleet.Warning{Message: err.Error(), File: yamlFile})
+			continue
+		}
+
+		slog.Info("linted file", "file", yamlFile, "issues", len(issues))
+		for _, issue := range issues {
+			summary.Warn(mscpfleet.Warning{Message: fmt.Sprintf("[%s] %s", issue.Rule, issue.Message), Policy: issue.Policy, File: yamlFile})
+		}
+		summary.Count("files", 1)
+		totalIssues += len(issues)
+	}
+
+	summary.Count("issues", totalIssues)
+	slog.Info("lint complete", "issues", totalIssues)
+	if totalIssues > 0 {
+		return fmt.Errorf("%w: %d policy queries cannot express failure", mscpfleet.ErrValidation, totalIssues)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// SetupLogging configures the default logger to write leveled records to
+// stderr as text or JSON, keeping stdout for command output
+func SetupLogging(format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q (use debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// RunSummary is the machine-readable record of a single command run
+type RunSummary struct {
+	Command  string    `json:"command"`
+	Source   string    `json:"source,omitempty"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	*mscpfleet.Diagnostics
+}
+
+// NewRunSummary starts the summary for a command, logging its warnings
+// through the default logger
+func NewRunSummary(command string) *RunSummary {
+	diagnostics := mscpfleet.NewDiagnostics()
+	diagnostics.SetLogger(slog.Default())
+	return &RunSummary{
+		Command:     command,
+		Status:      "running",
+		Started:     time.Now(),
+		Diagnostics: diagnostics,
+	}
+}
+
+// Finish records the outcome of the run
+func (s *RunSummary) Finish(err error) {
+	s.Duration = time.Since(s.Started).Round(time.Millisecond).String()
+	s.Status = "success"
+	if err != nil {
+		s.Status = "failed"
+		s.Error = err.Error()
+	}
+}
+
+// Write saves the summary as JSON
+func (s *RunSummary) Write(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run summary: %w", err)
+	}
+	if err := mscpfleet.WriteFileAtomic(filename, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write run summary %s: %w", filename, err)
+	}
+	return nil
+}
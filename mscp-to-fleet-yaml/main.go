@@ -1,66 +1,147 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 func main() {
-	var (
-		command = flag.String("command", "", "Command to run: convert, fix-queries, fix-specific, comprehensive")
-		help    = flag.Bool("help", false, "Show help")
-	)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	flag.Parse()
+// run parses the global flags, the command and its flags, applies the
+// environment and config file, runs the command and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	args, legacy := translateLegacyArgs(args)
 
-	if *help || *command == "" {
-		showHelp()
-		return
+	// Global flags given before the command are parsed separately and
+	// replayed onto the command's flag set, which registers them again
+	var leading, global globalOptions
+	globalFlags := flag.NewFlagSet(programName, flag.ContinueOnError)
+	globalFlags.SetOutput(stderr)
+	addGlobalFlags(globalFlags, &leading)
+	globalFlags.Usage = func() { printUsage(globalFlags.Output()) }
+	if err := globalFlags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return ExitInput
 	}
 
-	switch *command {
-	case "convert":
-		if err := RunConvert(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+	rest := globalFlags.Args()
+	if len(rest) == 0 {
+		printUsage(stdout)
+		return 0
+	}
+	if rest[0] == "help" {
+		return runHelp(rest[1:], stdout, stderr)
+	}
+	cmd := findCommand(rest[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", rest[0])
+		printUsage(stderr)
+		return ExitInput
+	}
+
+	fs, runCommand := newFlagSet(cmd, &global, stderr)
+	globalFlags.Visit(func(f *flag.Flag) { fs.Set(f.Name, f.Value.String()) })
+	if err := fs.Parse(rest[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
 		}
-	case "fix-queries":
-		if err := RunFixQueries(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		return ExitInput
+	}
+
+	configFile, required := global.config, global.config != ""
+	if !required {
+		if configFile, required = os.LookupEnv(envName("config")); !required {
+			configFile = defaultConfigPath()
+		}
+	}
+	config, err := LoadConfig(configFile, required)
+	if err == nil {
+		err = applyDefaults(fs, cmd.name, config)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitInput
+	}
+
+	if err := SetupLogging(global.logFormat, global.logLevel); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitInput
+	}
+	if legacy {
+		slog.Warn("-command is deprecated, pass the command name first", "command", cmd.name)
+	}
+
+	summary := NewRunSummary(cmd.name)
+	err = runCommand(fs.Args(), summary)
+	summary.Finish(err)
+	if global.summary != "" {
+		if writeErr := summary.Write(global.summary); writeErr != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", writeErr)
 		}
-	case "fix-specific":
-		if err := RunFixSpecific(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return ExitCode(err)
+	}
+	return 0
+}
+
+// runHelp prints the help of a command, or the top-level help
+func runHelp(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stdout)
+		return 0
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command: %s\n", args[0])
+		return ExitInput
+	}
+	fs, _ := newFlagSet(cmd, &globalOptions{}, stdout)
+	printCommandUsage(stdout, cmd, fs)
+	return 0
+}
+
+// translateLegacyArgs rewrites the old "-command <name>" form so existing
+// scripts keep working, moving the command name in front of the flags
+func translateLegacyArgs(args []string) ([]string, bool) {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			continue
 		}
-	case "comprehensive":
-		if err := RunComprehensive(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+		var value string
+		switch {
+		case name == "command" && i+1 < len(args):
+			value = args[i+1]
+			args = append(append([]string{}, args[:i]...), args[i+2:]...)
+		case strings.HasPrefix(name, "command="):
+			value = strings.TrimPrefix(name, "command=")
+			args = append(append([]string{}, args[:i]...), args[i+1:]...)
+		default:
+			continue
 		}
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", *command)
-		showHelp()
-		os.Exit(1)
+		return append([]string{value}, args...), true
 	}
+	return args, false
 }
 
-func showHelp() {
-	fmt.Println("Fleet Policy Converter - Convert macOS Security Compliance Project to Fleet YAML")
-	fmt.Println("")
-	fmt.Println("Usage:")
-	fmt.Println("  go run . -command <command> [options]")
-	fmt.Println("")
-	fmt.Println("Commands:")
-	fmt.Println("  convert      - Convert baselines to Fleet-compatible YAML format")
-	fmt.Println("  fix-queries  - Fix generic queries in existing YAML files")
-	fmt.Println("  fix-specific - Fix specific query patterns based on policy names")
-	fmt.Println("  comprehensive - Comprehensive query fixing with pattern matching")
-	fmt.Println("")
-	fmt.Println("Examples:")
-	fmt.Println("  go run . -command convert")
-	fmt.Println("  go run . -command fix-queries")
-	fmt.Println("  go run . -command comprehensive")
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,196 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OS version scoping modes
+const (
+	OSScopeNone  = "none"
+	OSScopeQuery = "query"
+	OSScopeLabel = "label"
+)
+
+// osScopePattern matches a query wrapped by scopeQueryToOSVersions
+var osScopePattern = regexp.MustCompile(`(?is)^SELECT 1 WHERE NOT EXISTS \(SELECT 1 FROM os_version WHERE .*?\) OR EXISTS \((.*)\);?$`)
+
+// nonAutomatedTags are mSCP tags marking rules that cannot be checked automatically
+var nonAutomatedTags = []string{"inherent", "permanent", "n_a", "manual"}
+
+// SkippedRule records a rule that was not converted to a policy
+type SkippedRule struct {
+	RuleID string
+	Reason string
+}
+
+// NonAutomatedTag returns the tag marking the rule as not automatically
+// checkable, or an empty string
+func (r *Rule) NonAutomatedTag() string {
+	for _, tag := range r.Tags {
+		for _, nonAutomated := range nonAutomatedTags {
+			if tag == nonAutomated {
+				return tag
+			}
+		}
+	}
+	return ""
+}
+
+// SupportsMacOS reports whether the rule applies to macOS. Rules without a
+// platforms section are macOS rules.
+func (r *Rule) SupportsMacOS() bool {
+	if len(r.Platforms) == 0 {
+		return true
+	}
+	_, ok := r.Platforms["macOS"]
+	return ok
+}
+
+// OSVersions returns the macOS versions the rule applies to
+func (r *Rule) OSVersions() []string {
+	versions := append([]string{}, r.MacOS...)
+	if platform, ok := r.Platforms["macOS"].(map[string]interface{}); ok {
+		for key := range platform {
+			if _, _, ok := parseOSVersion(key); ok {
+				versions = append(versions, key)
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// SkipReason returns why a rule cannot become a Fleet policy, or an empty string
+func (r *Rule) SkipReason() string {
+	if !r.SupportsMacOS() {
+		return "not supported on macOS"
+	}
+	if tag := r.NonAutomatedTag(); tag != "" {
+		return fmt.Sprintf("tagged %s", tag)
+	}
+	return ""
+}
+
+// parseOSVersion parses a macOS version such as "26.0" or "10.15" into the
+// components that identify a release: the major version, and the minor
+// version for releases before macOS 11
+func parseOSVersion(version string) (int, int, bool) {
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor := -1
+	if major == 10 && len(parts) > 1 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
+}
+
+// osVersionPredicate builds an os_version condition matching any of the given releases
+func osVersionPredicate(versions []string) string {
+	seen := map[string]bool{}
+	var conditions []string
+	for _, version := range versions {
+		major, minor, ok := parseOSVersion(version)
+		if !ok {
+			continue
+		}
+		condition := eq("major", major)
+		if minor >= 0 {
+			condition = "(" + and(eq("major", major), eq("minor", minor)) + ")"
+		}
+		if !seen[condition] {
+			seen[condition] = true
+			conditions = append(conditions, condition)
+		}
+	}
+	return or(conditions...)
+}
+
+// osVersionLabel returns the Fleet label name for a macOS release
+func osVersionLabel(version string) string {
+	major, minor, ok := parseOSVersion(version)
+	if !ok {
+		return ""
+	}
+	if minor >= 0 {
+		return fmt.Sprintf("macOS %d.%d", major, minor)
+	}
+	return fmt.Sprintf("macOS %d", major)
+}
+
+// scopeQueryToOSVersions wraps a query so hosts on releases the rule does not
+// apply to pass instead of being evaluated against it
+func scopeQueryToOSVersions(query string, versions []string) string {
+	predicate := osVersionPredicate(versions)
+	if predicate == "" {
+		return query
+	}
+	return "SELECT 1 WHERE NOT EXISTS (" + selectFrom("os_version", predicate) + ") OR EXISTS (" + trimQuery(query) + ");"
+}
+
+// unscopeQuery removes the OS version wrapper added by scopeQueryToOSVersions
+func unscopeQuery(query string) string {
+	normalized := strings.Join(strings.Fields(query), " ")
+	if m := osScopePattern.FindStringSubmatch(normalized); m != nil {
+		return m[1] + ";"
+	}
+	return strings.TrimSpace(query)
+}
+
+// applyOSScope restricts a policy to the macOS releases its rule applies to,
+// returning the label names used in label mode
+func applyOSScope(policy *FleetPolicy, rule *Rule, mode string) []string {
+	versions := rule.OSVersions()
+	if len(versions) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case OSScopeQuery:
+		policy.Spec.Query = scopeQueryToOSVersions(policy.Spec.Query, versions)
+	case OSScopeLabel:
+		var labels []string
+		for _, version := range versions {
+			if label := osVersionLabel(version); label != "" && !containsString(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+		policy.Spec.LabelsIncludeAny = labels
+		return labels
+	}
+	return nil
+}
+
+// createOSVersionLabel creates the Fleet label spec used to scope policies to a release
+func createOSVersionLabel(name string) *FleetLabel {
+	version := strings.TrimPrefix(name, "macOS ")
+	return &FleetLabel{
+		APIVersion: "v1",
+		Kind:       "label",
+		Spec: LabelSpec{
+			Name:                name,
+			Description:         fmt.Sprintf("Hosts running %s, used to scope macOS Security Compliance Project policies", name),
+			Query:               selectFrom("os_version", osVersionPredicate([]string{version})) + ";",
+			Platform:            "darwin",
+			LabelMembershipType: "dynamic",
+		},
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,375 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Text formats for policy descriptions and resolutions
+const (
+	TextMarkdown = "markdown"
+	TextPlain    = "plain"
+)
+
+// Patterns for the AsciiDoc subset used in mSCP rule discussions and fixes, compiled once
+var (
+	adocAttributePattern  = regexp.MustCompile(`^\[([^\[\]]*)\]$`)
+	adocAdmonitionPattern = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocListPattern       = regexp.MustCompile(`^(\*{1,5}|-|\.{1,5})\s+(.*)$`)
+	adocHeadingPattern    = regexp.MustCompile(`^(={1,5})\s+(.*)$`)
+	adocBlockTitlePattern = regexp.MustCompile(`^\.([^.\s].*)$`)
+	adocCodeSpanPattern   = regexp.MustCompile("`\\+(.+?)\\+`|`([^`]+)`")
+	adocLinkPattern       = regexp.MustCompile(`(?:link:)?((?:https?|ftp|mailto):[^\s\[\]]+)\[([^\]]*)\]`)
+	adocXrefPattern       = regexp.MustCompile(`<<([^,>]+)(?:,\s*([^>]+))?>>`)
+	adocStrongPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*|(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*([^\w*]|$)`)
+	adocEmphasisPattern   = regexp.MustCompile(`__([^_]+)__|(^|[^\w_])_([^_\s](?:[^_]*[^_\s])?)_([^\w_]|$)`)
+	adocPlaceholder       = regexp.MustCompile("\x00([0-9]+)\x00")
+	extraNewlinesPattern  = regexp.MustCompile(`\n\s*\n\s*\n+`)
+)
+
+// adocAdmonitionLabels are the display names of AsciiDoc admonitions
+var adocAdmonitionLabels = map[string]string{
+	"NOTE":      "Note",
+	"TIP":       "Tip",
+	"IMPORTANT": "Important",
+	"WARNING":   "Warning",
+	"CAUTION":   "Caution",
+}
+
+// asciiDocToMarkdown converts the AsciiDoc used in mSCP rules to Markdown:
+// source and listing blocks become fenced code, admonitions become block
+// quotes, and lists, links, inline code, emphasis and tables are kept
+func asciiDocToMarkdown(text string) string {
+	return renderAsciiDoc(text, true)
+}
+
+// cleanText converts the AsciiDoc used in mSCP rules to plain text. Code
+// blocks are kept verbatim as their own paragraphs, lists and tables keep one
+// item or row per line, and markup is removed without touching identifiers
+// that contain underscores.
+func cleanText(text string) string {
+	return renderAsciiDoc(text, false)
+}
+
+// renderText converts rule text to the given format
+func renderText(text, format string) string {
+	if format == TextPlain {
+		return cleanText(text)
+	}
+	return asciiDocToMarkdown(text)
+}
+
+// asciidocRenderer renders one AsciiDoc document line by line
+type asciidocRenderer struct {
+	markdown  bool
+	lines     []string
+	pos       int
+	out       []string
+	attribute string
+	counters  map[int]int
+}
+
+// renderAsciiDoc renders text as Markdown or plain text
+func renderAsciiDoc(text string, markdown bool) string {
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	r := &asciidocRenderer{
+		markdown: markdown,
+		lines:    strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"),
+		counters: map[int]int{},
+	}
+	r.render()
+
+	result := strings.Join(r.out, "\n")
+	result = extraNewlinesPattern.ReplaceAllString(result, "\n\n")
+	return strings.Trim(result, "\n")
+}
+
+// render converts every line, dispatching on block delimiters and line markup
+func (r *asciidocRenderer) render() {
+	for r.pos < len(r.lines) {
+		line := strings.TrimRight(r.lines[r.pos], " \t")
+		trimmed := strings.TrimSpace(line)
+		r.pos++
+
+		switch {
+		case trimmed == "":
+			r.counters = map[int]int{}
+			r.emit("")
+		case trimmed == "+":
+			// List continuation marker; the following block stays with the item
+		case adocAttributePattern.MatchString(trimmed) && !adocLinkPattern.MatchString(trimmed):
+			r.attribute = adocAttributePattern.FindStringSubmatch(trimmed)[1]
+		case isDelimiter(trimmed, '-') || isDelimiter(trimmed, '.'):
+			r.codeBlock(trimmed)
+		case isDelimiter(trimmed, '=') || isDelimiter(trimmed, '*'):
+			r.compoundBlock(trimmed)
+		case trimmed == "|===":
+			r.table()
+		case adocAdmonitionPattern.MatchString(trimmed):
+			m := adocAdmonitionPattern.FindStringSubmatch(trimmed)
+			r.admonition(m[1], []string{r.paragraph(m[2])})
+		case adocListPattern.MatchString(trimmed):
+			m := adocListPattern.FindStringSubmatch(trimmed)
+			r.listItem(m[1], r.paragraph(m[2]))
+		case adocHeadingPattern.MatchString(trimmed):
+			m := adocHeadingPattern.FindStringSubmatch(trimmed)
+			if r.markdown {
+				r.emit(strings.Repeat("#", len(m[1])) + " " + r.inline(m[2]))
+			} else {
+				r.emit(r.inline(m[2]))
+			}
+		case adocBlockTitlePattern.MatchString(trimmed):
+			title := r.inline(adocBlockTitlePattern.FindStringSubmatch(trimmed)[1])
+			if r.markdown {
+				title = "**" + title + "**"
+			}
+			r.emit(title)
+		default:
+			r.emit(r.inline(strings.TrimSuffix(trimmed, " +")))
+		}
+		if trimmed != "" && !adocAttributePattern.MatchString(trimmed) {
+			r.attribute = ""
+		}
+	}
+}
+
+// isDelimiter reports whether line is a block delimiter of at least four c characters
+func isDelimiter(line string, c byte) bool {
+	return len(line) >= 4 && strings.Trim(line, string(c)) == ""
+}
+
+// emit appends an output line
+func (r *asciidocRenderer) emit(line string) {
+	r.out = append(r.out, line)
+}
+
+// blockLines returns the lines up to the closing delimiter and moves past it
+func (r *asciidocRenderer) blockLines(delimiter string) []string {
+	var body []string
+	for r.pos < len(r.lines) {
+		line := strings.TrimRight(r.lines[r.pos], " \t")
+		r.pos++
+		if strings.TrimSpace(line) == delimiter {
+			break
+		}
+		body = append(body, line)
+	}
+	return body
+}
+
+// paragraph joins the continuation lines of a paragraph, list item or
+// admonition to its first line
+func (r *asciidocRenderer) paragraph(first string) string {
+	parts := []string{first}
+	for r.pos < len(r.lines) {
+		next := strings.TrimSpace(r.lines[r.pos])
+		if next == "" || next == "+" || next == "|===" || adocListPattern.MatchString(next) ||
+			adocAdmonitionPattern.MatchString(next) || adocAttributePattern.MatchString(next) ||
+			isDelimiter(next, '-') || isDelimiter(next, '.') || isDelimiter(next, '=') || isDelimiter(next, '*') {
+			break
+		}
+		parts = append(parts, next)
+		r.pos++
+	}
+	return r.inline(strings.TrimSuffix(strings.Join(parts, " "), " +"))
+}
+
+// codeBlock renders a source or listing block verbatim
+func (r *asciidocRenderer) codeBlock(delimiter string) {
+	body := r.blockLines(delimiter)
+	language := ""
+	if fields := strings.Split(r.attribute, ","); len(fields) > 1 && strings.TrimSpace(fields[0]) == "source" {
+		language = strings.TrimSpace(fields[1])
+	}
+
+	r.emit("")
+	if r.markdown {
+		r.emit("```" + language)
+		r.out = append(r.out, body...)
+		r.emit("```")
+	} else {
+		r.out = append(r.out, body...)
+	}
+	r.emit("")
+}
+
+// compoundBlock renders an example or sidebar block, or a block admonition
+// such as [NOTE] followed by ====
+func (r *asciidocRenderer) compoundBlock(delimiter string) {
+	attribute := r.attribute
+	inner := renderAsciiDoc(strings.Join(r.blockLines(delimiter), "\n"), r.markdown)
+	if _, ok := adocAdmonitionLabels[attribute]; ok {
+		r.admonition(attribute, strings.Split(inner, "\n"))
+		return
+	}
+	r.emit("")
+	r.out = append(r.out, strings.Split(inner, "\n")...)
+	r.emit("")
+}
+
+// admonition renders a note, tip, important, warning or caution
+func (r *asciidocRenderer) admonition(kind string, body []string) {
+	r.emit("")
+	if r.markdown {
+		r.emit("> **" + adocAdmonitionLabels[kind] + ":** " + body[0])
+		for _, line := range body[1:] {
+			r.emit(strings.TrimRight("> "+line, " "))
+		}
+	} else {
+		r.emit(kind + ": " + body[0])
+		r.out = append(r.out, body[1:]...)
+	}
+	r.emit("")
+}
+
+// listItem renders an ordered or unordered list item, nested by marker length
+func (r *asciidocRenderer) listItem(marker, text string) {
+	depth := len(marker) - 1
+	indent := strings.Repeat("  ", depth)
+	if marker[0] != '.' {
+		r.emit(indent + "- " + text)
+		return
+	}
+
+	r.counters[depth]++
+	for deeper := range r.counters {
+		if deeper > depth {
+			delete(r.counters, deeper)
+		}
+	}
+	number := "1"
+	if !r.markdown {
+		number = strconv.Itoa(r.counters[depth])
+	}
+	r.emit(strings.Repeat("   ", depth) + number + ". " + text)
+}
+
+// table renders a |=== table, using the first row as the Markdown header
+func (r *asciidocRenderer) table() {
+	body := r.blockLines("|===")
+
+	columns := tableColumns(r.attribute)
+	var cells []string
+	for _, line := range body {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		parts := strings.Split(line, "|")[1:]
+		if columns == 0 {
+			columns = len(parts)
+		}
+		for _, cell := range parts {
+			cells = append(cells, r.inline(strings.TrimSpace(cell)))
+		}
+	}
+	if columns == 0 {
+		return
+	}
+
+	r.emit("")
+	for start := 0; start < len(cells); start += columns {
+		row := make([]string, columns)
+		copy(row, cells[start:])
+		if r.markdown {
+			for i := range row {
+				row[i] = strings.ReplaceAll(row[i], "|", `\|`)
+			}
+			r.emit("| " + strings.Join(row, " | ") + " |")
+			if start == 0 {
+				r.emit("|" + strings.Repeat(" --- |", columns))
+			}
+		} else {
+			r.emit(strings.Join(row, " | "))
+		}
+	}
+	r.emit("")
+}
+
+// tableColumns returns the column count from a cols="..." attribute, or 0
+func tableColumns(attribute string) int {
+	i := strings.Index(attribute, "cols=")
+	if i < 0 {
+		return 0
+	}
+	spec := strings.Trim(strings.SplitN(attribute[i+len("cols="):], " ", 2)[0], `"'`)
+	if n, err := strconv.Atoi(strings.TrimSuffix(spec, "*")); err == nil && strings.HasSuffix(spec, "*") {
+		return n
+	}
+	return len(strings.Split(spec, ","))
+}
+
+// inline converts inline code, links, cross references and emphasis. Code
+// spans and links are set aside first so their contents are left alone.
+func (r *asciidocRenderer) inline(text string) string {
+	var held []string
+	hold := func(s string) string {
+		held = append(held, s)
+		return fmt.Sprintf("\x00%d\x00", len(held)-1)
+	}
+
+	text = adocCodeSpanPattern.ReplaceAllStringFunc(text, func(span string) string {
+		m := adocCodeSpanPattern.FindStringSubmatch(span)
+		code := m[1] + m[2]
+		if r.markdown {
+			return hold("`" + code + "`")
+		}
+		return hold(code)
+	})
+	text = adocLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := adocLinkPattern.FindStringSubmatch(link)
+		url, label := m[1], strings.TrimSpace(m[2])
+		switch {
+		case label == "" && r.markdown:
+			return hold("<" + url + ">")
+		case label == "":
+			return hold(url)
+		case r.markdown:
+			return hold("[" + label + "](" + url + ")")
+		}
+		return hold(label + " (" + url + ")")
+	})
+	text = adocXrefPattern.ReplaceAllStringFunc(text, func(xref string) string {
+		m := adocXrefPattern.FindStringSubmatch(xref)
+		if m[2] != "" {
+			return m[2]
+		}
+		return m[1]
+	})
+
+	mark := ""
+	if r.markdown {
+		mark = "*"
+	}
+	text = replaceConstrained(adocStrongPattern, text, mark+mark)
+	text = replaceConstrained(adocEmphasisPattern, text, mark)
+
+	return adocPlaceholder.ReplaceAllStringFunc(text, func(p string) string {
+		i, _ := strconv.Atoi(adocPlaceholder.FindStringSubmatch(p)[1])
+		return held[i]
+	})
+}
+
+// replaceConstrained rewrites unconstrained (doubled) and constrained
+// (word-bounded) AsciiDoc formatting marks to the given Markdown mark, keeping
+// the characters around constrained marks. Repeats until nothing changes so
+// adjacent spans that share a boundary character are all converted.
+func replaceConstrained(pattern *regexp.Regexp, text, mark string) string {
+	for {
+		replaced := pattern.ReplaceAllStringFunc(text, func(span string) string {
+			m := pattern.FindStringSubmatch(span)
+			if m[1] != "" {
+				return mark + m[1] + mark
+			}
+			return m[2] + mark + m[3] + mark + m[4]
+		})
+		if replaced == text {
+			return text
+		}
+		text = replaced
+	}
+}
@@ -0,0 +1,119 @@
+package mscpfleet
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// auditControlPath is the audit daemon's configuration file
+const auditControlPath = "/etc/security/audit_control"
+
+// audit_control settings checked by mSCP audit rules
+const (
+	auditFlags       = "flags"
+	auditNAFlags     = "naflags"
+	auditMinFree     = "minfree"
+	auditExpireAfter = "expire-after"
+	auditPolicy      = "policy"
+)
+
+// auditTokenSettings hold comma-separated lists checked token by token
+var auditTokenSettings = []string{auditFlags, auditNAFlags, auditPolicy}
+
+var (
+	auditSettingPattern = regexp.MustCompile(`['"/]\^?(naflags|flags|minfree|expire-after|policy)\b`)
+	auditGrepPattern    = regexp.MustCompile(`grep\s+-[A-Za-z]*c[A-Za-z]*\s+['"]([^'"]+)['"]`)
+)
+
+// auditValueExpr is the value of an audit_control line with the setting name
+// and spaces removed, e.g. "lo,aa,ad" for "flags:lo, aa,ad"
+const auditValueExpr = "REPLACE(SUBSTR(line, INSTR(line, ':') + 1), ' ', '')"
+
+// auditControlCheck is a test of one audit_control setting. Flag and policy
+// settings are comma-separated lists that must contain every token; minfree
+// and expire-after must equal a value.
+type auditControlCheck struct {
+	Setting string
+	Tokens  []string
+	Value   string
+}
+
+// parseAuditControlCheck reads the setting and tokens or value a rule's check
+// script tests in audit_control. mSCP checks either count tokens, as in
+//
+//	awk -F':' '/^flags/ { print $NF }' /etc/security/audit_control | tr ',' '\n' | grep -Ec 'aa'
+//
+// or print the value and compare it with the rule's result.
+func parseAuditControlCheck(rule *Rule) (auditControlCheck, bool) {
+	if !strings.Contains(rule.Check, auditControlPath) {
+		return auditControlCheck{}, false
+	}
+	m := auditSettingPattern.FindStringSubmatch(rule.Check)
+	if m == nil {
+		return auditControlCheck{}, false
+	}
+	check := auditControlCheck{Setting: m[1]}
+
+	if grep := auditGrepPattern.FindStringSubmatch(rule.Check); grep != nil {
+		if !containsString(auditTokenSettings, check.Setting) {
+			return auditControlCheck{}, false
+		}
+		token := strings.NewReplacer(`\`, "", "^", "", "$", "").Replace(grep[1])
+		if token == "" || strings.ContainsAny(token, "|.*[]()") {
+			return auditControlCheck{}, false
+		}
+		if expected, ok := expectedResult(rule.Result); !ok || expected != 1 {
+			return auditControlCheck{}, false
+		}
+		check.Tokens = []string{token}
+		return check, true
+	}
+
+	expected, ok := expectedResult(rule.Result)
+	if !ok {
+		return auditControlCheck{}, false
+	}
+	switch v := expected.(type) {
+	case string:
+		check.Value = v
+	case int:
+		check.Value = strconv.Itoa(v)
+	default:
+		return auditControlCheck{}, false
+	}
+	if containsString(auditTokenSettings, check.Setting) {
+		check.Tokens = strings.Split(check.Value, ",")
+		check.Value = ""
+	}
+	return check, true
+}
+
+// Query returns a policy query that passes when audit_control has the
+// setting with every token or the exact value. Tokens are matched as whole
+// list entries, so "aa" does not match "-aa" or a comment mentioning it.
+func (c auditControlCheck) Query() string {
+	conditions := []string{eq("path", auditControlPath), hasPrefix("line", c.Setting+":")}
+	for _, token := range c.Tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			conditions = append(conditions, contains("',' || "+auditValueExpr+" || ','", ","+token+","))
+		}
+	}
+	if c.Value != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(c.Value)); err == nil {
+			conditions = append(conditions, eq("CAST("+auditValueExpr+" AS INTEGER)", n))
+		} else {
+			conditions = append(conditions, eq(auditValueExpr, strings.ReplaceAll(c.Value, " ", "")))
+		}
+	}
+	return passIfExists(selectFrom("file_lines", conditions...))
+}
+
+// auditControlQuery builds the query for rules whose check reads audit_control settings
+func auditControlQuery(rule *Rule) (string, bool) {
+	check, ok := parseAuditControlCheck(rule)
+	if !ok {
+		return "", false
+	}
+	return check.Query(), true
+}
@@ -0,0 +1,97 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Author is a person credited in a baseline's authors table
+type Author struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// String returns the author as "Name (Organization)"
+func (a Author) String() string {
+	if a.Organization == "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, a.Organization)
+}
+
+// AuthorList parses the AsciiDoc table mSCP uses for baseline authors,
+// skipping the Name|Organization header row
+func (b *Baseline) AuthorList() []Author {
+	var authors []Author
+	for _, line := range strings.Split(b.Authors, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") || strings.HasPrefix(line, "|===") {
+			continue
+		}
+		cells := strings.Split(strings.TrimPrefix(line, "|"), "|")
+		author := Author{Name: strings.TrimSpace(cells[0])}
+		if len(cells) > 1 {
+			author.Organization = strings.TrimSpace(cells[1])
+		}
+		if author.Name == "" || (strings.EqualFold(author.Name, "name") && strings.EqualFold(author.Organization, "organization")) {
+			continue
+		}
+		authors = append(authors, author)
+	}
+	return authors
+}
+
+// BaselineInfo is the provenance of a baseline, carried into output file
+// headers, GitOps team files and the compliance report
+type BaselineInfo struct {
+	Description  string   `json:"description,omitempty"`
+	Authors      []Author `json:"authors,omitempty"`
+	ParentValues string   `json:"parent_values,omitempty"`
+	// Parent is the baseline a tailored baseline was built from
+	Parent string   `json:"parent,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// Merged lists the baselines a merged baseline was built from
+	Merged []string `json:"merged,omitempty"`
+}
+
+// Info returns the provenance of the baseline
+func (b *Baseline) Info() BaselineInfo {
+	return BaselineInfo{
+		Description:  strings.TrimSpace(b.Description),
+		Authors:      b.AuthorList(),
+		ParentValues: b.ParentValues,
+		Parent:       b.Parent,
+		Tags:         b.Tags,
+	}
+}
+
+// CommentLines renders the provenance as lines for a YAML comment block
+func (info BaselineInfo) CommentLines() []string {
+	var lines []string
+	if info.Parent != "" {
+		lines = append(lines, "Tailored from: "+info.Parent)
+	}
+	if len(info.Merged) > 0 {
+		lines = append(lines, "Merged from: "+strings.Join(info.Merged, ", "))
+	}
+	if info.ParentValues != "" {
+		lines = append(lines, "Parent values: "+info.ParentValues)
+	}
+	if len(info.Authors) > 0 {
+		names := make([]string, 0, len(info.Authors))
+		for _, author := range info.Authors {
+			names = append(names, author.String())
+		}
+		lines = append(lines, "Authors: "+strings.Join(names, ", "))
+	}
+	if len(info.Tags) > 0 {
+		lines = append(lines, "Tags: "+strings.Join(info.Tags, ", "))
+	}
+	if info.Description != "" {
+		lines = append(lines, "")
+		for _, line := range strings.Split(cleanText(info.Description), "\n") {
+			lines = append(lines, strings.TrimRight(line, " "))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,55 @@
+package mscpfleet
+
+import "sync"
+
+// RuleCache holds parsed rules so each rule file is read once per run, however
+// many baselines include it. It is safe for concurrent use; cached rules are
+// shared and must not be modified.
+type RuleCache struct {
+	mu      sync.Mutex
+	entries map[string]*ruleCacheEntry
+}
+
+// ruleCacheEntry is a rule that is loaded at most once
+type ruleCacheEntry struct {
+	once sync.Once
+	rule *Rule
+	err  error
+}
+
+// NewRuleCache creates an empty rule cache
+func NewRuleCache() *RuleCache {
+	return &RuleCache{entries: map[string]*ruleCacheEntry{}}
+}
+
+// Get returns the cached rule, calling load the first time the rule is
+// requested. Concurrent callers for the same rule wait for a single load.
+// A nil cache calls load every time.
+func (rc *RuleCache) Get(ruleID string, load func() (*Rule, error)) (*Rule, error) {
+	if rc == nil {
+		return load()
+	}
+
+	rc.mu.Lock()
+	entry, ok := rc.entries[ruleID]
+	if !ok {
+		entry = &ruleCacheEntry{}
+		rc.entries[ruleID] = entry
+	}
+	rc.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.rule, entry.err = load()
+	})
+	return entry.rule, entry.err
+}
+
+// Len returns the number of rules requested so far
+func (rc *RuleCache) Len() int {
+	if rc == nil {
+		return 0
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.entries)
+}
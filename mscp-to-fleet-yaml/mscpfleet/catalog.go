@@ -0,0 +1,81 @@
+package mscpfleet
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// CatalogEntry is a hand-tuned query for a single rule
+type CatalogEntry struct {
+	Query    string `yaml:"query"`
+	Policy   string `yaml:"policy,omitempty"`
+	Source   string `yaml:"source,omitempty"`
+	Imported string `yaml:"imported,omitempty"`
+}
+
+// QueryCatalog maps mSCP rule IDs to queries that take precedence over generated ones
+type QueryCatalog struct {
+	Queries map[string]CatalogEntry `yaml:"queries"`
+}
+
+// LoadQueryCatalog loads a query catalog. A missing file is an empty catalog
+// so the first import can create it.
+func LoadQueryCatalog(filename string) (*QueryCatalog, error) {
+	catalog := &QueryCatalog{Queries: map[string]CatalogEntry{}}
+	if err := loadYAML(filename, catalog); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return catalog, nil
+		}
+		return nil, fmt.Errorf("failed to load query catalog %s: %w", filename, err)
+	}
+	if catalog.Queries == nil {
+		catalog.Queries = map[string]CatalogEntry{}
+	}
+	return catalog, nil
+}
+
+// Lookup returns the catalog entry for a rule, or nil
+func (qc *QueryCatalog) Lookup(ruleID string) *CatalogEntry {
+	if qc == nil {
+		return nil
+	}
+	entry, ok := qc.Queries[ruleID]
+	if !ok {
+		return nil
+	}
+	return &entry
+}
+
+// Set records the query for a rule
+func (qc *QueryCatalog) Set(ruleID string, entry CatalogEntry) {
+	qc.Queries[ruleID] = entry
+}
+
+// Import records the query of an imported policy for a rule, without the OS
+// version scope the converter adds, unless it is what the converter already
+// generates for the rule or what the catalog holds. It reports whether the
+// catalog changed.
+func (qc *QueryCatalog) Import(rule *Rule, query string, entry CatalogEntry) bool {
+	query = unscopeQuery(query)
+	if existing := qc.Lookup(rule.ID); existing != nil && normalizeQuery(query) == normalizeQuery(existing.Query) {
+		return false
+	}
+	if matchesGenerated(rule, query) {
+		return false
+	}
+	entry.Query = query
+	qc.Set(rule.ID, entry)
+	return true
+}
+
+// Save writes the catalog to a file
+func (qc *QueryCatalog) Save(filename string) error {
+	data, err := marshalYAML(qc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query catalog: %w", err)
+	}
+	header := "# Rule ID to query catalog for the macOS Security Compliance Project converter\n" +
+		"# Queries here take precedence over generated ones\n\n"
+	return WriteFileAtomic(filename, append([]byte(header), data...))
+}
@@ -0,0 +1,579 @@
+package mscpfleet
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BaselineConverter handles conversion of baselines to Fleet format
+type BaselineConverter struct {
+	source        *Source
+	baselinesDir  string
+	rulesDir      string
+	team          string
+	exemptions    *ExemptionSet
+	tailorings    []*Tailoring
+	selected      []string
+	catalog       *QueryCatalog
+	diagnostics   *Diagnostics
+	osScope       string
+	policyOptions PolicyOptions
+	critical      CriticalRules
+	nameTemplate  *NameTemplate
+	labels        map[string]bool
+	report        *ComplianceReport
+	strict        bool
+	rules         *RuleCache
+	workers       int
+	mu            sync.Mutex // guards labels while baselines convert in parallel
+}
+
+// NewBaselineConverter creates a new baseline converter reading from an mSCP source
+func NewBaselineConverter(source *Source) *BaselineConverter {
+	return &BaselineConverter{
+		source:       source,
+		baselinesDir: "baselines",
+		rulesDir:     "rules",
+		osScope:      OSScopeQuery,
+		labels:       map[string]bool{},
+		report:       NewComplianceReport(""),
+		diagnostics:  NewDiagnostics(),
+		rules:        NewRuleCache(),
+		workers:      runtime.NumCPU(),
+	}
+}
+
+// SetWorkers sets how many baselines are converted at once; n < 1 uses one per CPU
+func (bc *BaselineConverter) SetWorkers(n int) {
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+	bc.workers = n
+}
+
+// SetRuleCache sets the cache parsed rules are shared through; nil disables caching
+func (bc *BaselineConverter) SetRuleCache(cache *RuleCache) {
+	bc.rules = cache
+}
+
+// SetOSScope sets how policies are scoped to the macOS releases their rules apply to
+func (bc *BaselineConverter) SetOSScope(mode string) error {
+	switch mode {
+	case OSScopeNone, OSScopeQuery, OSScopeLabel:
+		bc.osScope = mode
+		return nil
+	}
+	return fmt.Errorf("unknown OS scope %q (use %s, %s or %s)", mode, OSScopeNone, OSScopeQuery, OSScopeLabel)
+}
+
+// SetTextFormat sets how rule discussions and fixes are rendered: markdown or plain
+func (bc *BaselineConverter) SetTextFormat(format string) error {
+	switch format {
+	case TextMarkdown, TextPlain:
+		bc.policyOptions.TextFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown text format %q (use %s or %s)", format, TextMarkdown, TextPlain)
+}
+
+// SetPosture sets how rules that check the device state and have profile
+// keys are converted: state, profile or both
+func (bc *BaselineConverter) SetPosture(mode string) error {
+	switch mode {
+	case PostureState, PostureProfile, PostureBoth:
+		bc.policyOptions.Posture = mode
+		return nil
+	}
+	return fmt.Errorf("unknown posture mode %q (use %s, %s or %s)", mode, PostureState, PostureProfile, PostureBoth)
+}
+
+// SetSectionPrefix puts the baseline section in front of the rule title in policy names
+func (bc *BaselineConverter) SetSectionPrefix(prefix bool) {
+	bc.policyOptions.SectionPrefix = prefix
+}
+
+// SetNameTemplate sets the template policy names are rendered from (see NameTemplate)
+func (bc *BaselineConverter) SetNameTemplate(text string) error {
+	template, err := ParseNameTemplate(text)
+	if err != nil {
+		return err
+	}
+	bc.nameTemplate = template
+	return nil
+}
+
+// NameTemplate returns the template policy names are rendered from: the one
+// set, or the default with or without the section prefix
+func (bc *BaselineConverter) NameTemplate() *NameTemplate {
+	switch {
+	case bc.nameTemplate != nil:
+		return bc.nameTemplate
+	case bc.policyOptions.SectionPrefix:
+		return &NameTemplate{text: SectionNameTemplate}
+	}
+	return &NameTemplate{text: DefaultNameTemplate}
+}
+
+// SetCriticalRules sets which policies are marked critical in Fleet
+func (bc *BaselineConverter) SetCriticalRules(critical CriticalRules) {
+	bc.critical = critical
+}
+
+// SetStrict makes baseline failures stop the conversion, and StrictCheck
+// report missing rules, unmapped queries and validation problems
+func (bc *BaselineConverter) SetStrict(strict bool) {
+	bc.strict = strict
+}
+
+// SetDiagnostics sets the collector that records counts and warnings
+func (bc *BaselineConverter) SetDiagnostics(diagnostics *Diagnostics) {
+	bc.diagnostics = diagnostics
+}
+
+// Diagnostics returns the collector that records counts and warnings
+func (bc *BaselineConverter) Diagnostics() *Diagnostics {
+	return bc.diagnostics
+}
+
+// SetCatalog sets the query catalog whose entries replace generated queries
+func (bc *BaselineConverter) SetCatalog(catalog *QueryCatalog) {
+	bc.catalog = catalog
+}
+
+// SetExemptions sets the exemptions applied for the given team
+func (bc *BaselineConverter) SetExemptions(exemptions *ExemptionSet, team string) {
+	bc.exemptions = exemptions
+	bc.team = team
+	bc.report.Team = team
+}
+
+// SetBaselines limits conversion to the named baselines and tailorings; no
+// names converts all of them
+func (bc *BaselineConverter) SetBaselines(names []string) {
+	bc.selected = names
+}
+
+// AddTailoring adds a tailored baseline to convert alongside the stock baselines
+func (bc *BaselineConverter) AddTailoring(tailoring *Tailoring) {
+	bc.tailorings = append(bc.tailorings, tailoring)
+}
+
+// LoadRule loads a rule definition from the rules directory, parsing each
+// rule once per converter. The returned rule is shared and must not be modified.
+func (bc *BaselineConverter) LoadRule(ruleID string) (*Rule, error) {
+	return bc.rules.Get(ruleID, func() (*Rule, error) {
+		return bc.readRule(ruleID)
+	})
+}
+
+// readRule reads and parses a rule definition from the rules directory
+func (bc *BaselineConverter) readRule(ruleID string) (*Rule, error) {
+	// Try different possible locations for the rule
+	possiblePaths := []string{
+		path.Join(bc.rulesDir, "os", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "system_settings", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "audit", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "auth", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "icloud", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "pwpolicy", ruleID+".yaml"),
+		path.Join(bc.rulesDir, "supplemental", ruleID+".yaml"),
+	}
+
+	for _, rulePath := range possiblePaths {
+		if _, err := fs.Stat(bc.source.FS, rulePath); err == nil {
+			var rule Rule
+			err := loadYAMLFS(bc.source.FS, rulePath, &rule)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load rule %s from %s: %w", ruleID, rulePath, err)
+			}
+			return &rule, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID)
+}
+
+// LoadBaseline loads a baseline by name from the baselines directory
+func (bc *BaselineConverter) LoadBaseline(name string) (*Baseline, error) {
+	baselinePath := path.Join(bc.baselinesDir, name+".yaml")
+	var baseline Baseline
+	if err := loadYAMLFS(bc.source.FS, baselinePath, &baseline); err != nil {
+		return nil, InputError(fmt.Errorf("failed to load baseline %s: %w", baselinePath, err))
+	}
+	return &baseline, nil
+}
+
+// ConvertBaselineToFleet converts the baseline file at baselinePath to Fleet policies
+func (bc *BaselineConverter) ConvertBaselineToFleet(baselinePath string) (*BaselineResult, error) {
+	var baseline Baseline
+	err := loadYAMLFS(bc.source.FS, baselinePath, &baseline)
+	if err != nil {
+		return nil, InputError(fmt.Errorf("failed to load baseline %s: %w", baselinePath, err))
+	}
+
+	baselineName := baselineNameFromPath(baselinePath)
+	title := baseline.Title
+	if title == "" {
+		title = baselineName
+	}
+	return bc.ConvertBaseline(&baseline, baselineName, bc.report.AddBaseline(baselineName, title))
+}
+
+// ConvertedRule pairs a generated policy with the rule it was built from
+type ConvertedRule struct {
+	Rule    *Rule
+	Section string
+	ODV     interface{}
+	Policy  *FleetPolicy
+	// Severity, Exemption and NameVars are what the policy was built with,
+	// so merged policies can be rebuilt from them
+	Severity  string
+	Exemption *Exemption
+	NameVars  NameVars
+}
+
+// BuildPolicies converts the rules of an in-memory baseline to Fleet
+// policies, recording skipped and exempted rules in the given report entry
+func (bc *BaselineConverter) BuildPolicies(baseline *Baseline, baselineName string, baselineReport *BaselineReport) []*ConvertedRule {
+	converted := []*ConvertedRule{}
+	summary := bc.diagnostics.Baseline(baselineName)
+	names := bc.NameTemplate()
+	odvKey := baseline.ODVKey(baselineName)
+
+	// Process each section and its rules
+	for _, section := range baseline.Profile {
+		rules := section.Rules
+
+		for _, ruleID := range rules {
+			rule, err := bc.LoadRule(ruleID)
+			if err != nil {
+				summary.RulesMissing++
+				message := err.Error()
+				if errors.Is(err, ErrRuleNotFound) {
+					message = "rule not found"
+				}
+				bc.diagnostics.Warn(Warning{Message: message, RuleID: ruleID, Baseline: baselineName})
+				continue
+			}
+			summary.RulesLoaded++
+			if reason := rule.SkipReason(); reason != "" {
+				baselineReport.Skipped = append(baselineReport.Skipped, SkippedRule{RuleID: rule.ID, Reason: reason})
+				summary.Skipped++
+				bc.diagnostics.Logger().Debug("rule not checked automatically", "rule_id", rule.ID, "baseline", baselineName, "reason", reason)
+				continue
+			}
+
+			odv := baseline.ODVValue(rule, baselineName)
+			rule = rule.WithODV(odv)
+
+			exemption := bc.exemptions.Lookup(rule.ID, baselineName, bc.team)
+			if exemption != nil {
+				baselineReport.Exempted = append(baselineReport.Exempted, ExemptedRule{RuleID: rule.ID, Exemption: *exemption})
+				summary.Exempted++
+				if exemption.Action == ExemptionOmit {
+					bc.diagnostics.Logger().Info("exempted rule omitted", "rule_id", rule.ID, "baseline", baselineName)
+					continue
+				}
+			}
+
+			policy := CreateFleetPolicy(rule, baselineName, bc.policyOptions)
+			if policy != nil {
+				if entry := bc.catalog.Lookup(rule.ID); entry != nil {
+					// Check the catalog query before it is scoped, as generated ones are
+					if err := ParseSQL(entry.Query); err != nil {
+						summary.Invalid++
+						bc.diagnostics.Warn(Warning{Message: fmt.Sprintf("catalog query does not parse, using the generated query: %v", err), RuleID: rule.ID, Baseline: baselineName})
+					} else {
+						policy.Spec.Query = normalizeQuery(entry.Query)
+					}
+				} else if keys := uncheckedProfileKeys(rule); len(keys) > 0 {
+					bc.diagnostics.Warn(Warning{Message: "check compares several profile keys with one result, not checked: " + strings.Join(keys, ", "), RuleID: rule.ID, Baseline: baselineName})
+				}
+				if isUnmappedQuery(policy.Spec.Query) {
+					summary.Unmapped++
+					bc.diagnostics.Warn(Warning{Message: "no query mapping, policy always passes", RuleID: rule.ID, Baseline: baselineName})
+				} else {
+					summary.Mapped++
+				}
+				labels := applyOSScope(policy, rule, bc.osScope)
+				bc.mu.Lock()
+				for _, label := range labels {
+					bc.labels[label] = true
+				}
+				bc.mu.Unlock()
+				severity := rule.SeverityFor(odvKey)
+				title := rule.Title
+				if title == "" {
+					title = rule.ID
+				}
+				nameVars := NameVars{
+					RuleID:     rule.ID,
+					Title:      title,
+					Baseline:   baselineName,
+					Section:    section.Section,
+					Severity:   severity,
+					References: rule.References,
+				}
+				policy.Spec.Name = names.Render(nameVars)
+				applySection(policy, section.Section)
+				applySeverity(policy, rule.ID, severity, bc.critical)
+				if exemption != nil {
+					applyExemption(policy, exemption)
+				}
+				if policy.Spec.Critical {
+					summary.Critical++
+				}
+				converted = append(converted, &ConvertedRule{Rule: rule, Section: section.Section, ODV: odv, Policy: policy,
+					Severity: severity, Exemption: exemption, NameVars: nameVars})
+			}
+		}
+	}
+
+	return converted
+}
+
+// ConvertBaseline converts an in-memory baseline to Fleet policies ordered by
+// rule ID, validating each one and recording the results in the given report entry
+func (bc *BaselineConverter) ConvertBaseline(baseline *Baseline, baselineName string, baselineReport *BaselineReport) (*BaselineResult, error) {
+	summary := bc.diagnostics.Baseline(baselineName)
+	converted := bc.BuildPolicies(baseline, baselineName, baselineReport)
+
+	// Order by rule ID so output does not depend on section ordering
+	sort.SliceStable(converted, func(i, j int) bool { return converted[i].Rule.ID < converted[j].Rule.ID })
+
+	result := &BaselineResult{
+		Name:     baselineName,
+		Title:    baselineReport.Title,
+		Policies: make([]FleetPolicy, 0, len(converted)),
+		Sections: make([]string, 0, len(converted)),
+		RuleIDs:  make([]string, 0, len(converted)),
+		Info:     baseline.Info(),
+		names:    bc.NameTemplate(),
+	}
+	baselineReport.Info = result.Info
+	summary.Sections = map[string]int{}
+	names := map[string]string{}
+	for _, cr := range converted {
+		sort.Strings(cr.Policy.Spec.Tags)
+		sort.Strings(cr.Policy.Spec.LabelsIncludeAny)
+		issues := ValidatePolicy(cr.Policy)
+		if other, ok := names[cr.Policy.Spec.Name]; ok {
+			issues = append(issues, LintIssue{Policy: cr.Policy.Spec.Name, Rule: "duplicate-name", Message: "has the same name as the policy for " + other})
+		}
+		names[cr.Policy.Spec.Name] = cr.Rule.ID
+		if len(issues) > 0 {
+			summary.Invalid++
+		}
+		for _, issue := range issues {
+			bc.diagnostics.Warn(Warning{Message: fmt.Sprintf("[%s] %s", issue.Rule, issue.Message), RuleID: cr.Rule.ID, Baseline: baselineName, Policy: issue.Policy})
+		}
+		result.Policies = append(result.Policies, *cr.Policy)
+		result.Sections = append(result.Sections, cr.Section)
+		result.RuleIDs = append(result.RuleIDs, cr.Rule.ID)
+		result.converted = append(result.converted, cr)
+		summary.Sections[cr.Section]++
+	}
+
+	baselineReport.Policies = len(result.Policies)
+	bc.diagnostics.Logger().Info("converted baseline", "baseline", baselineName, "policies", len(result.Policies))
+	return result, nil
+}
+
+// ConvertAllBaselines converts every baseline in the source and every added
+// tailoring. Failed baselines are recorded as warnings, or returned as an
+// error in strict mode.
+func (bc *BaselineConverter) ConvertAllBaselines() (*Result, error) {
+	// Find all baseline files
+	baselineFiles, err := fs.Glob(bc.source.FS, path.Join(bc.baselinesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find baseline files: %w", err)
+	}
+
+	// Convert baselines and tailorings with a bounded pool of workers
+	jobs := make([]conversionJob, 0, len(baselineFiles)+len(bc.tailorings))
+	for _, baselineFile := range baselineFiles {
+		baselineFile := baselineFile
+		jobs = append(jobs, conversionJob{
+			name:    baselineNameFromPath(baselineFile),
+			label:   baselineFile,
+			convert: func() (*BaselineResult, error) { return bc.ConvertBaselineToFleet(baselineFile) },
+		})
+	}
+	for _, tailoring := range bc.tailorings {
+		tailoring := tailoring
+		jobs = append(jobs, conversionJob{
+			name:    tailoring.Name,
+			label:   "tailored baseline " + tailoring.Name,
+			convert: func() (*BaselineResult, error) { return bc.ConvertTailoring(tailoring) },
+		})
+	}
+	jobs, err = bc.selectJobs(jobs)
+	if err != nil {
+		return nil, err
+	}
+	bc.runJobs(jobs)
+
+	result := &Result{SourceVersion: bc.source.Version, Report: bc.report, Diagnostics: bc.diagnostics}
+	totalPolicies := 0
+	for _, job := range jobs {
+		if job.err != nil {
+			if bc.strict {
+				return nil, fmt.Errorf("failed to convert %s: %w", job.label, job.err)
+			}
+			bc.diagnostics.Warn(Warning{Message: fmt.Sprintf("failed to convert %s: %v", job.label, job.err), Baseline: job.name})
+			result.Failed = append(result.Failed, job.name)
+			continue
+		}
+		result.Baselines = append(result.Baselines, job.result)
+		totalPolicies += len(job.result.Policies)
+	}
+
+	// Workers finish in any order; report baselines in the order they were listed
+	order := map[string]int{}
+	for i, job := range jobs {
+		order[job.name] = i
+	}
+	sort.SliceStable(bc.report.Baselines, func(i, j int) bool {
+		return order[bc.report.Baselines[i].Name] < order[bc.report.Baselines[j].Name]
+	})
+	sort.SliceStable(bc.diagnostics.Baselines, func(i, j int) bool {
+		return order[bc.diagnostics.Baselines[i].Name] < order[bc.diagnostics.Baselines[j].Name]
+	})
+	bc.diagnostics.Logger().Debug("rule cache", "rules", bc.rules.Len(), "workers", bc.workers)
+
+	// Policies of different baselines may share a name only when they are identical
+	for _, collision := range FindCollisions(result.Baselines) {
+		if len(collision.Baselines) < 2 {
+			continue // reported as duplicate-name when the baseline was validated
+		}
+		for _, name := range collision.Baselines {
+			bc.diagnostics.Baseline(name).Invalid++
+		}
+		bc.diagnostics.Warn(Warning{
+			Message: fmt.Sprintf("[name-collision] is used for different rules or queries: rules %s in %s", strings.Join(collision.RuleIDs, ", "), strings.Join(collision.Baselines, ", ")),
+			Policy:  collision.Name,
+		})
+	}
+
+	result.Labels = bc.Labels()
+	bc.report.SourceVersion = bc.source.Version
+	bc.diagnostics.Count("policies", totalPolicies)
+	return result, nil
+}
+
+// StrictCheck returns an error when the diagnostics record missing rules,
+// unmapped queries or invalid policies, in that order of precedence
+func (bc *BaselineConverter) StrictCheck() error {
+	var missing, unmapped, invalid int
+	for _, baseline := range bc.diagnostics.Baselines {
+		missing += baseline.RulesMissing
+		unmapped += baseline.Unmapped
+		invalid += baseline.Invalid
+	}
+	switch {
+	case missing > 0:
+		return fmt.Errorf("%w: %d rules could not be loaded", ErrInput, missing)
+	case unmapped > 0:
+		return fmt.Errorf("%w: %d rules have no query mapping", ErrMapping, unmapped)
+	case invalid > 0:
+		return fmt.Errorf("%w: %d policies failed validation", ErrValidation, invalid)
+	}
+	return nil
+}
+
+// conversionJob is one baseline or tailoring converted by the worker pool
+type conversionJob struct {
+	name    string
+	label   string
+	convert func() (*BaselineResult, error)
+	result  *BaselineResult
+	err     error
+}
+
+// selectJobs keeps the jobs of the selected baselines, in the order they were
+// selected, and fails for names that are neither a baseline nor a tailoring.
+// A tailoring named like a baseline in the source or another tailoring is
+// rejected, since both would write the same output file.
+func (bc *BaselineConverter) selectJobs(jobs []conversionJob) ([]conversionJob, error) {
+	byName := map[string]conversionJob{}
+	for _, job := range jobs {
+		if other, ok := byName[job.name]; ok {
+			return nil, fmt.Errorf("%w: %s has the same name as %s", ErrInput, job.label, other.label)
+		}
+		byName[job.name] = job
+	}
+	if len(bc.selected) == 0 {
+		return jobs, nil
+	}
+	selected := make([]conversionJob, 0, len(bc.selected))
+	seen := map[string]bool{}
+	for _, name := range bc.selected {
+		job, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown baseline %s", ErrInput, name)
+		}
+		if !seen[name] {
+			selected = append(selected, job)
+			seen[name] = true
+		}
+	}
+	return selected, nil
+}
+
+// runJobs runs the jobs on at most bc.workers goroutines, storing each result in its job
+func (bc *BaselineConverter) runJobs(jobs []conversionJob) {
+	workers := bc.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				jobs[i].result, jobs[i].err = jobs[i].convert()
+			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// Labels returns the Fleet labels used to scope policies to macOS releases, sorted by name
+func (bc *BaselineConverter) Labels() []FleetLabel {
+	bc.mu.Lock()
+	names := make([]string, 0, len(bc.labels))
+	for name := range bc.labels {
+		names = append(names, name)
+	}
+	bc.mu.Unlock()
+	sort.Strings(names)
+
+	labels := make([]FleetLabel, 0, len(names))
+	for _, name := range names {
+		labels = append(labels, *createOSVersionLabel(name))
+	}
+	return labels
+}
+
+// applyExemption marks an annotated policy as exempted and non-critical
+func applyExemption(policy *FleetPolicy, exemption *Exemption) {
+	policy.Spec.Critical = false
+	policy.Spec.Description = strings.TrimSpace(exemption.Annotation() + "\n\n" + policy.Spec.Description)
+	policy.Spec.Tags = append(policy.Spec.Tags, exemptedTag)
+}
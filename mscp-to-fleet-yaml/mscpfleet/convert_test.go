@@ -0,0 +1,148 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testRuleKinds are check scripts covering the main query generators
+var testRuleKinds = []struct {
+	check, result, mobileconfig string
+}{
+	{
+		check:        "/usr/bin/osascript -l JavaScript -e \"$.NSUserDefaults.alloc.initWithSuiteName('com.apple.example').objectForKey('Setting%d').js\"",
+		result:       "string: \"true\"",
+		mobileconfig: "com.apple.example:\n    Setting%d: true",
+	},
+	{
+		check:  "/usr/bin/awk -F':' '/^flags/ { print $NF }' /etc/security/audit_control | /usr/bin/tr ',' '\\n' | /usr/bin/grep -Ec 'flag%d'",
+		result: "integer: 1",
+	},
+	{
+		check:  "/usr/bin/stat -f %%A /etc/file%d",
+		result: "integer: 640",
+	},
+	{
+		check:  "/usr/bin/fdesetup status | /usr/bin/grep -c \"FileVault is On.\" # %d",
+		result: "integer: 1",
+	},
+}
+
+// testSourceFS returns an mSCP tree of baselines that each list most of the
+// rules, so rules are shared between baselines as in the real project
+func testSourceFS(baselines, rules int) fstest.MapFS {
+	fsys := fstest.MapFS{
+		"VERSION.yaml": {Data: []byte("os: \"26.0\"\nversion: \"Test Guidance\"\n")},
+	}
+	for i := 0; i < rules; i++ {
+		kind := testRuleKinds[i%len(testRuleKinds)]
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "id: os_rule_%d\ntitle: \"Rule %d\"\ndiscussion: |\n  Rule %d _MUST_ be configured.\n", i, i, i)
+		fmt.Fprintf(&sb, "check: |\n  %s\nresult:\n  %s\n", fmt.Sprintf(kind.check, i), kind.result)
+		fmt.Fprintf(&sb, "fix: |\n  Configure rule %d.\nmacOS:\n  - \"26.0\"\nseverity: \"medium\"\n", i)
+		if kind.mobileconfig != "" {
+			fmt.Fprintf(&sb, "mobileconfig: true\nmobileconfig_info:\n  %s\n", fmt.Sprintf(kind.mobileconfig, i))
+		}
+		fsys[fmt.Sprintf("rules/os/os_rule_%d.yaml", i)] = &fstest.MapFile{Data: []byte(sb.String())}
+	}
+	for b := 0; b < baselines; b++ {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "title: \"Baseline %d\"\ndescription: Test baseline %d.\nprofile:\n  - section: \"macOS\"\n    rules:\n", b, b)
+		for i := 0; i < rules; i++ {
+			if i%baselines != b {
+				fmt.Fprintf(&sb, "      - os_rule_%d\n", i)
+			}
+		}
+		fsys[fmt.Sprintf("baselines/baseline_%d.yaml", b)] = &fstest.MapFile{Data: []byte(sb.String())}
+	}
+	return fsys
+}
+
+// newTestSource wraps testSourceFS in a Source
+func newTestSource(tb testing.TB, baselines, rules int) *Source {
+	tb.Helper()
+	source, err := NewSource(testSourceFS(baselines, rules), "test", "test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return source
+}
+
+func TestConvertAllBaselinesWorkers(t *testing.T) {
+	source := newTestSource(t, 4, 40)
+
+	var outputs []string
+	for _, workers := range []int{1, 4} {
+		converter := NewBaselineConverter(source)
+		converter.SetWorkers(workers)
+		result, err := converter.ConvertAllBaselines()
+		if err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		if got := len(result.Baselines); got != 4 {
+			t.Fatalf("workers=%d: got %d baselines, want 4", workers, got)
+		}
+		if got := len(result.Policies()); got != 4*30 {
+			t.Fatalf("workers=%d: got %d policies, want %d", workers, got, 4*30)
+		}
+		for _, policy := range result.Policies() {
+			if isUnmappedQuery(policy.Spec.Query) {
+				t.Errorf("workers=%d: %s has no query mapping", workers, policy.Spec.Name)
+			}
+		}
+		var sb strings.Builder
+		for _, baseline := range result.Baselines {
+			data, err := MarshalPolicies(baseline, result.SourceVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb.Write(data)
+		}
+		outputs = append(outputs, sb.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("the worker pool produced different output than a single worker")
+	}
+}
+
+func BenchmarkConvertAllBaselines(b *testing.B) {
+	source := newTestSource(b, 6, 300)
+
+	for _, bm := range []struct {
+		name    string
+		workers int
+		warm    bool
+	}{
+		{"serial/cold", 1, false},
+		{"serial/warm", 1, true},
+		{"pool/cold", runtime.NumCPU(), false},
+		{"pool/warm", runtime.NumCPU(), true},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			// A warm cache is filled by a conversion before timing starts
+			cache := NewRuleCache()
+			if bm.warm {
+				converter := NewBaselineConverter(source)
+				converter.SetRuleCache(cache)
+				if _, err := converter.ConvertAllBaselines(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				converter := NewBaselineConverter(source)
+				converter.SetWorkers(bm.workers)
+				if bm.warm {
+					converter.SetRuleCache(cache)
+				}
+				if _, err := converter.ConvertAllBaselines(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
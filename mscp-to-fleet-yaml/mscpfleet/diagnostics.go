@@ -0,0 +1,114 @@
+package mscpfleet
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// Diagnostics collects per-baseline counts, named counters and warnings
+// during a conversion. Warnings and progress are also logged through its
+// logger, which discards everything unless SetLogger is called. It is safe
+// for concurrent use.
+type Diagnostics struct {
+	Counts    map[string]int     `json:"counts,omitempty"`
+	Baselines []*BaselineSummary `json:"baselines,omitempty"`
+	Warnings  []Warning          `json:"warnings"`
+
+	logger *slog.Logger
+	mu     sync.Mutex // guards Baselines, Warnings and Counts during parallel conversion
+}
+
+// BaselineSummary counts what happened to the rules of one baseline
+type BaselineSummary struct {
+	Name         string `json:"name"`
+	RulesLoaded  int    `json:"rules_loaded"`
+	RulesMissing int    `json:"rules_missing"`
+	Mapped       int    `json:"mapped"`
+	Unmapped     int    `json:"unmapped"`
+	Skipped      int    `json:"skipped"`
+	Exempted     int    `json:"exempted"`
+	Invalid      int    `json:"invalid"`
+	Critical     int    `json:"critical"`
+	Written      int    `json:"written"`
+	// Sections counts the policies generated for each baseline section
+	Sections map[string]int `json:"sections,omitempty"`
+}
+
+// Warning is a problem that did not stop the run
+type Warning struct {
+	Message  string `json:"message"`
+	RuleID   string `json:"rule_id,omitempty"`
+	Baseline string `json:"baseline,omitempty"`
+	Policy   string `json:"policy,omitempty"`
+	File     string `json:"file,omitempty"`
+}
+
+// NewDiagnostics creates an empty diagnostics collector
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{
+		Counts:   map[string]int{},
+		Warnings: []Warning{},
+		logger:   discardLogger(),
+	}
+}
+
+// discardLogger returns a logger that drops every record
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// SetLogger sets the logger that warnings and progress are reported to
+func (d *Diagnostics) SetLogger(logger *slog.Logger) {
+	d.logger = logger
+}
+
+// Logger returns the logger that warnings and progress are reported to
+func (d *Diagnostics) Logger() *slog.Logger {
+	if d.logger == nil {
+		return discardLogger()
+	}
+	return d.logger
+}
+
+// Baseline returns the summary entry for a baseline, creating it on first use
+func (d *Diagnostics) Baseline(name string) *BaselineSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, baseline := range d.Baselines {
+		if baseline.Name == name {
+			return baseline
+		}
+	}
+	baseline := &BaselineSummary{Name: name}
+	d.Baselines = append(d.Baselines, baseline)
+	return baseline
+}
+
+// Warn logs a warning and records it
+func (d *Diagnostics) Warn(w Warning) {
+	var attrs []any
+	if w.RuleID != "" {
+		attrs = append(attrs, "rule_id", w.RuleID)
+	}
+	if w.Baseline != "" {
+		attrs = append(attrs, "baseline", w.Baseline)
+	}
+	if w.Policy != "" {
+		attrs = append(attrs, "policy", w.Policy)
+	}
+	if w.File != "" {
+		attrs = append(attrs, "file", w.File)
+	}
+	d.Logger().Warn(w.Message, attrs...)
+	d.mu.Lock()
+	d.Warnings = append(d.Warnings, w)
+	d.mu.Unlock()
+}
+
+// Count adds n to a named counter
+func (d *Diagnostics) Count(name string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Counts[name] += n
+}
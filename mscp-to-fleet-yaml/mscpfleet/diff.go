@@ -0,0 +1,419 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// QueryChange records a policy query that changed between versions
+type QueryChange struct {
+	Rule string `json:"rule"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// ReferenceChange records compliance references added to or removed from a rule
+type ReferenceChange struct {
+	Rule    string   `json:"rule"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// TagChange records policy tags added to or removed from a policy in an output directory
+type TagChange struct {
+	Rule    string   `json:"rule"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ODVDiff records an organization defined value that changed between versions
+type ODVDiff struct {
+	Rule string `json:"rule"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// BaselineDiff describes how one baseline changed between versions
+type BaselineDiff struct {
+	Name              string            `json:"name"`
+	Status            string            `json:"status"`
+	AddedRules        []string          `json:"added_rules,omitempty"`
+	RemovedRules      []string          `json:"removed_rules,omitempty"`
+	RewordedRules     []string          `json:"reworded_rules,omitempty"`
+	ChangedQueries    []QueryChange     `json:"changed_queries,omitempty"`
+	ChangedReferences []ReferenceChange `json:"changed_references,omitempty"`
+	ChangedTags       []TagChange       `json:"changed_tags,omitempty"`
+	ChangedODVs       []ODVDiff         `json:"changed_odvs,omitempty"`
+}
+
+// DiffReport describes the differences between two mSCP versions or output directories
+type DiffReport struct {
+	Old       string         `json:"old"`
+	New       string         `json:"new"`
+	Baselines []BaselineDiff `json:"baselines"`
+}
+
+// diffEntry is the comparable form of a single policy
+type diffEntry struct {
+	Text       string
+	Query      string
+	References []string
+	Tags       []string
+	ODV        string
+}
+
+// DiffSide holds the policies of every baseline on one side of a diff, keyed
+// by rule ID for mSCP sources and by policy name for output directories
+type DiffSide struct {
+	Label     string
+	baselines map[string]map[string]diffEntry
+	// byName is set for output directories, whose policies are keyed by name
+	byName bool
+}
+
+// LoadDiffSide loads an mSCP source, read at ref when it is a git
+// repository, or a directory of generated policy files
+func LoadDiffSide(location, ref string) (*DiffSide, error) {
+	if ref == "" && isPolicyDir(location) {
+		return loadPolicyDirSide(location)
+	}
+
+	source, err := OpenSource(location, ref)
+	if err != nil {
+		return nil, err
+	}
+	return loadSourceSide(source)
+}
+
+// isPolicyDir reports whether dir holds generated policy files rather than an mSCP tree
+func isPolicyDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "baselines")); err == nil {
+		return false
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
+	return len(files) > 0
+}
+
+// loadSourceSide converts every baseline of an mSCP source in memory
+func loadSourceSide(source *Source) (*DiffSide, error) {
+	converter := NewBaselineConverter(source)
+	baselineFiles, err := fs.Glob(source.FS, path.Join(converter.baselinesDir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find baseline files: %w", err)
+	}
+
+	// Name the location too; two unversioned directories share a version
+	label := source.Location
+	if source.Version != "" {
+		label = fmt.Sprintf("%s (%s)", source.Location, source.Version)
+	}
+	side := &DiffSide{Label: label, baselines: map[string]map[string]diffEntry{}}
+	for _, baselineFile := range baselineFiles {
+		var baseline Baseline
+		if err := loadYAMLFS(source.FS, baselineFile, &baseline); err != nil {
+			return nil, fmt.Errorf("failed to load baseline %s: %w", baselineFile, err)
+		}
+
+		baselineName := baselineNameFromPath(baselineFile)
+		entries := map[string]diffEntry{}
+		for _, cr := range converter.BuildPolicies(&baseline, baselineName, &BaselineReport{Name: baselineName}) {
+			// Compare the text before ODV substitution so ODV changes are
+			// not also reported as rewording
+			raw, err := converter.LoadRule(cr.Rule.ID)
+			if err != nil {
+				raw = cr.Rule
+			}
+			entry := diffEntry{
+				Text:       raw.Title + "\n" + raw.Discussion,
+				Query:      cr.Policy.Spec.Query,
+				References: flattenReferences(cr.Rule.References),
+			}
+			if cr.ODV != nil {
+				entry.ODV = fmt.Sprint(cr.ODV)
+			}
+			entries[cr.Rule.ID] = entry
+		}
+		side.baselines[baselineName] = entries
+	}
+	return side, nil
+}
+
+// loadPolicyDirSide loads every policy file in a directory of generated output
+func loadPolicyDirSide(dir string) (*DiffSide, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*-fleet-policies.yml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find YAML files: %w", err)
+	}
+
+	side := &DiffSide{Label: dir, baselines: map[string]map[string]diffEntry{}, byName: true}
+	for _, file := range files {
+		policies, err := LoadPolicies(file)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := map[string]diffEntry{}
+		for _, policy := range policies {
+			tags := append([]string{}, policy.Spec.Tags...)
+			sort.Strings(tags)
+			entries[policy.Spec.Name] = diffEntry{
+				Text:  policy.Spec.Description,
+				Query: policy.Spec.Query,
+				Tags:  tags,
+			}
+		}
+		side.baselines[strings.TrimSuffix(filepath.Base(file), "-fleet-policies.yml")] = entries
+	}
+	return side, nil
+}
+
+// flattenReferences turns a rule's references into sorted "framework: id" strings
+func flattenReferences(references map[string]interface{}) []string {
+	var flat []string
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for _, key := range sortedKeys(t) {
+				walk(path.Join(prefix, key), t[key])
+			}
+		case []interface{}:
+			for _, item := range t {
+				walk(prefix, item)
+			}
+		case nil:
+		default:
+			flat = append(flat, fmt.Sprintf("%s: %v", prefix, t))
+		}
+	}
+	walk("", references)
+	sort.Strings(flat)
+	return flat
+}
+
+// DiffSides compares two sides baseline by baseline
+func DiffSides(oldSide, newSide *DiffSide) *DiffReport {
+	report := &DiffReport{Old: oldSide.Label, New: newSide.Label, Baselines: []BaselineDiff{}}
+
+	names := map[string]bool{}
+	for name := range oldSide.baselines {
+		names[name] = true
+	}
+	for name := range newSide.baselines {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		oldEntries, inOld := oldSide.baselines[name]
+		newEntries, inNew := newSide.baselines[name]
+
+		bd := BaselineDiff{Name: name, Status: "changed"}
+		switch {
+		case !inOld:
+			bd.Status = "added"
+		case !inNew:
+			bd.Status = "removed"
+		}
+
+		// Policy names carry their ODV, so a policy of an output directory
+		// whose ODV changed is matched to its renamed counterpart
+		renamed := map[string]string{}
+		if oldSide.byName && newSide.byName {
+			renamed = renamedByODV(oldEntries, newEntries)
+		}
+		paired := map[string]bool{}
+		for _, key := range renamed {
+			paired[key] = true
+		}
+
+		for _, key := range sortedEntryKeys(newEntries) {
+			if _, ok := oldEntries[key]; !ok && !paired[key] {
+				bd.AddedRules = append(bd.AddedRules, key)
+			}
+		}
+		for _, key := range sortedEntryKeys(oldEntries) {
+			oldEntry := oldEntries[key]
+			newKey, isRenamed := renamed[key]
+			if !isRenamed {
+				newKey = key
+			}
+			newEntry, ok := newEntries[newKey]
+			if !ok {
+				bd.RemovedRules = append(bd.RemovedRules, key)
+				continue
+			}
+			if isRenamed {
+				oldEntry.ODV, newEntry.ODV = odvValues(key, newKey)
+				oldEntry.Text = odvValuePattern.ReplaceAllString(oldEntry.Text, "$$ODV")
+				newEntry.Text = odvValuePattern.ReplaceAllString(newEntry.Text, "$$ODV")
+			}
+			if oldEntry.Text != newEntry.Text {
+				bd.RewordedRules = append(bd.RewordedRules, newKey)
+			}
+			// Compare the checks without the OS version scope, which names
+			// the release each side was generated for
+			if normalizeQuery(unscopeQuery(oldEntry.Query)) != normalizeQuery(unscopeQuery(newEntry.Query)) {
+				bd.ChangedQueries = append(bd.ChangedQueries, QueryChange{Rule: newKey, Old: oldEntry.Query, New: newEntry.Query})
+			}
+			if !reflect.DeepEqual(oldEntry.References, newEntry.References) {
+				added, removed := diffStrings(oldEntry.References, newEntry.References)
+				bd.ChangedReferences = append(bd.ChangedReferences, ReferenceChange{Rule: newKey, Added: added, Removed: removed})
+			}
+			if !reflect.DeepEqual(oldEntry.Tags, newEntry.Tags) {
+				added, removed := diffStrings(oldEntry.Tags, newEntry.Tags)
+				bd.ChangedTags = append(bd.ChangedTags, TagChange{Rule: newKey, Added: added, Removed: removed})
+			}
+			if oldEntry.ODV != newEntry.ODV {
+				bd.ChangedODVs = append(bd.ChangedODVs, ODVDiff{Rule: newKey, Old: oldEntry.ODV, New: newEntry.ODV})
+			}
+		}
+
+		if bd.Status != "changed" || bd.hasChanges() {
+			report.Baselines = append(report.Baselines, bd)
+		}
+	}
+	return report
+}
+
+// hasChanges reports whether anything in the baseline changed
+func (bd BaselineDiff) hasChanges() bool {
+	return len(bd.AddedRules)+len(bd.RemovedRules)+len(bd.RewordedRules)+
+		len(bd.ChangedQueries)+len(bd.ChangedReferences)+len(bd.ChangedTags)+len(bd.ChangedODVs) > 0
+}
+
+// Text renders the diff report for reading in a terminal or pull request
+func (dr *DiffReport) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Old: %s\nNew: %s\n", dr.Old, dr.New)
+	if len(dr.Baselines) == 0 {
+		sb.WriteString("\nNo differences found.\n")
+		return sb.String()
+	}
+
+	for _, bd := range dr.Baselines {
+		fmt.Fprintf(&sb, "\n== %s (%s) ==\n", bd.Name, bd.Status)
+		for _, rule := range bd.AddedRules {
+			fmt.Fprintf(&sb, "  + %s\n", rule)
+		}
+		for _, rule := range bd.RemovedRules {
+			fmt.Fprintf(&sb, "  - %s\n", rule)
+		}
+		for _, rule := range bd.RewordedRules {
+			fmt.Fprintf(&sb, "  ~ %s: reworded\n", rule)
+		}
+		for _, change := range bd.ChangedQueries {
+			fmt.Fprintf(&sb, "  ~ %s: query changed\n      old: %s\n      new: %s\n", change.Rule, change.Old, change.New)
+		}
+		for _, change := range bd.ChangedReferences {
+			fmt.Fprintf(&sb, "  ~ %s: references changed\n", change.Rule)
+			for _, ref := range change.Added {
+				fmt.Fprintf(&sb, "      + %s\n", ref)
+			}
+			for _, ref := range change.Removed {
+				fmt.Fprintf(&sb, "      - %s\n", ref)
+			}
+		}
+		for _, change := range bd.ChangedTags {
+			fmt.Fprintf(&sb, "  ~ %s: tags changed\n", change.Rule)
+			for _, tag := range change.Added {
+				fmt.Fprintf(&sb, "      + %s\n", tag)
+			}
+			for _, tag := range change.Removed {
+				fmt.Fprintf(&sb, "      - %s\n", tag)
+			}
+		}
+		for _, change := range bd.ChangedODVs {
+			fmt.Fprintf(&sb, "  ~ %s: ODV %s -> %s\n", change.Rule, change.Old, change.New)
+		}
+	}
+	return sb.String()
+}
+
+// odvValuePattern matches the numbers an ODV substitutes into policy names and text
+var odvValuePattern = regexp.MustCompile(`[0-9]+`)
+
+// renamedByODV pairs the policies only on one side whose names differ only in
+// their numbers, such as "Require a Minimum Password Length of 15 Characters"
+// and "... of 16 Characters". It maps each old name to the new one and skips
+// names whose shape matches more than one policy on either side.
+func renamedByODV(oldEntries, newEntries map[string]diffEntry) map[string]string {
+	shapes := func(entries, other map[string]diffEntry) map[string][]string {
+		byShape := map[string][]string{}
+		for name := range entries {
+			if _, ok := other[name]; !ok {
+				shape := odvValuePattern.ReplaceAllString(name, "#")
+				byShape[shape] = append(byShape[shape], name)
+			}
+		}
+		return byShape
+	}
+	oldShapes, newShapes := shapes(oldEntries, newEntries), shapes(newEntries, oldEntries)
+
+	renamed := map[string]string{}
+	for shape, oldNames := range oldShapes {
+		newNames := newShapes[shape]
+		if len(oldNames) == 1 && len(newNames) == 1 && shape != oldNames[0] {
+			renamed[oldNames[0]] = newNames[0]
+		}
+	}
+	return renamed
+}
+
+// odvValues returns the numbers that differ between two names renamed by an ODV change
+func odvValues(oldName, newName string) (string, string) {
+	oldValues := odvValuePattern.FindAllString(oldName, -1)
+	newValues := odvValuePattern.FindAllString(newName, -1)
+	var changedOld, changedNew []string
+	for i := range oldValues {
+		if i < len(newValues) && oldValues[i] != newValues[i] {
+			changedOld = append(changedOld, oldValues[i])
+			changedNew = append(changedNew, newValues[i])
+		}
+	}
+	return strings.Join(changedOld, ", "), strings.Join(changedNew, ", ")
+}
+
+// sortedEntryKeys returns the keys of a baseline's entries in sorted order
+func sortedEntryKeys(entries map[string]diffEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffStrings returns the items only in b (added) and only in a (removed)
+func diffStrings(a, b []string) ([]string, []string) {
+	inA := map[string]bool{}
+	for _, item := range a {
+		inA[item] = true
+	}
+	inB := map[string]bool{}
+	var added []string
+	for _, item := range b {
+		inB[item] = true
+		if !inA[item] {
+			added = append(added, item)
+		}
+	}
+	var removed []string
+	for _, item := range a {
+		if !inB[item] {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,23 @@
+// Package mscpfleet converts macOS Security Compliance Project (mSCP)
+// baselines into Fleet policies.
+//
+// The converter reads an mSCP tree through an fs.FS, so a checkout, a
+// release archive, a git ref or an embedded copy all work the same way.
+// Conversion happens in memory and returns a Result holding the policies of
+// each baseline, the release scoping labels, the compliance report and the
+// Diagnostics collected along the way; writing files is a separate step.
+//
+//	result, err := mscpfleet.Convert(os.DirFS("macos_security"), mscpfleet.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	for _, policy := range result.Policies() {
+//		fmt.Println(policy.Spec.Name)
+//	}
+//
+// Use OpenSource and NewBaselineConverter for finer control, and WriteOutput
+// to write the same files as the mscp-to-fleet-yaml command. Errors wrap
+// ErrInput, ErrMapping or ErrValidation so callers can tell them apart with
+// errors.Is. Nothing is logged unless Options.Logger is set; warnings are
+// always collected in the Result's Diagnostics.
+package mscpfleet
@@ -0,0 +1,25 @@
+package mscpfleet
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInput marks problems with the mSCP source, input files or options
+	ErrInput = errors.New("input error")
+	// ErrMapping marks rules whose check could not be turned into a query
+	ErrMapping = errors.New("mapping gap")
+	// ErrValidation marks generated or existing policies that fail validation
+	ErrValidation = errors.New("validation failed")
+	// ErrRuleNotFound is returned when a baseline lists a rule the source does not have
+	ErrRuleNotFound = errors.New("rule not found")
+)
+
+// InputError marks err as an input error, keeping its message
+func InputError(err error) error {
+	if err == nil || errors.Is(err, ErrInput) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrInput, err)
+}
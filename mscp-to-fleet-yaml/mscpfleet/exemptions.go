@@ -0,0 +1,147 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exemption actions
+const (
+	ExemptionOmit     = "omit"
+	ExemptionAnnotate = "annotate"
+)
+
+// exemptedTag marks the policies of annotated exemptions
+const exemptedTag = "exempted"
+
+// DateLayout is the format of exemption expiry and catalog import dates
+const DateLayout = "2006-01-02"
+
+// Exemption represents an accepted risk for a single rule
+type Exemption struct {
+	RuleID        string `yaml:"rule_id"`
+	Baseline      string `yaml:"baseline"`
+	Team          string `yaml:"team"`
+	Justification string `yaml:"justification"`
+	Approver      string `yaml:"approver"`
+	Expires       string `yaml:"expires"`
+	Action        string `yaml:"action"`
+}
+
+// ExemptionsFile represents the exemptions file structure
+type ExemptionsFile struct {
+	Exemptions []Exemption `yaml:"exemptions"`
+}
+
+// ExemptionSet holds validated exemptions for lookup during conversion
+type ExemptionSet struct {
+	exemptions []Exemption
+}
+
+// Scope describes where the exemption applies
+func (e Exemption) Scope() string {
+	switch {
+	case e.Baseline != "" && e.Team != "":
+		return fmt.Sprintf("baseline %s, team %s", e.Baseline, e.Team)
+	case e.Baseline != "":
+		return fmt.Sprintf("baseline %s", e.Baseline)
+	case e.Team != "":
+		return fmt.Sprintf("team %s", e.Team)
+	default:
+		return "global"
+	}
+}
+
+// Annotation returns the text added to the description of annotated policies
+func (e Exemption) Annotation() string {
+	return fmt.Sprintf("EXEMPTED (%s): %s Approved by %s, expires %s.", e.Scope(), e.Justification, e.Approver, e.Expires)
+}
+
+// LoadExemptions loads and validates an exemptions file. Expired exemptions
+// are reported as an error so the run fails until they are renewed or removed.
+func LoadExemptions(filename string, now time.Time) (*ExemptionSet, error) {
+	var file ExemptionsFile
+	if err := loadYAML(filename, &file); err != nil {
+		return nil, fmt.Errorf("failed to load exemptions %s: %w", filename, err)
+	}
+
+	var problems, expired []string
+	// Expiry dates are calendar dates in the caller's time zone
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	for i := range file.Exemptions {
+		e := &file.Exemptions[i]
+		if e.Action == "" {
+			e.Action = ExemptionOmit
+		}
+
+		label := fmt.Sprintf("exemption %d (%s)", i+1, e.RuleID)
+		if e.RuleID == "" {
+			problems = append(problems, fmt.Sprintf("exemption %d: rule_id is required", i+1))
+		}
+		if strings.TrimSpace(e.Justification) == "" {
+			problems = append(problems, label+": justification is required")
+		}
+		if strings.TrimSpace(e.Approver) == "" {
+			problems = append(problems, label+": approver is required")
+		}
+		if e.Action != ExemptionOmit && e.Action != ExemptionAnnotate {
+			problems = append(problems, fmt.Sprintf("%s: unknown action %q (use %s or %s)", label, e.Action, ExemptionOmit, ExemptionAnnotate))
+		}
+
+		expires, err := time.ParseInLocation(DateLayout, e.Expires, now.Location())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: expires must be a date in YYYY-MM-DD format", label))
+			continue
+		}
+		if expires.Before(today) {
+			expired = append(expired, fmt.Sprintf("%s expired on %s (approved by %s)", label, e.Expires, e.Approver))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid exemptions in %s:\n  %s", filename, strings.Join(problems, "\n  "))
+	}
+	if len(expired) > 0 {
+		return nil, fmt.Errorf("expired exemptions in %s:\n  %s", filename, strings.Join(expired, "\n  "))
+	}
+
+	return &ExemptionSet{exemptions: file.Exemptions}, nil
+}
+
+// Lookup returns the most specific exemption for a rule in the given baseline
+// and team, or nil if the rule is not exempted
+func (es *ExemptionSet) Lookup(ruleID, baselineName, team string) *Exemption {
+	if es == nil {
+		return nil
+	}
+
+	var match *Exemption
+	bestScore := -1
+	for i := range es.exemptions {
+		e := &es.exemptions[i]
+		if e.RuleID != ruleID {
+			continue
+		}
+		if e.Baseline != "" && e.Baseline != baselineName {
+			continue
+		}
+		if e.Team != "" && e.Team != team {
+			continue
+		}
+
+		score := 0
+		if e.Baseline != "" {
+			score++
+		}
+		if e.Team != "" {
+			score += 2
+		}
+		if score > bestScore {
+			match = e
+			bestScore = score
+		}
+	}
+	return match
+}
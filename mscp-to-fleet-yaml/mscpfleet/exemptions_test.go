@@ -0,0 +1,42 @@
+package mscpfleet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadExemptionsExpiry(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "exemptions.yml")
+	data := "exemptions:\n" +
+		"  - rule_id: os_sip_enable\n" +
+		"    justification: Kernel debugging lab\n" +
+		"    approver: Security\n" +
+		"    expires: \"2026-03-10\"\n"
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	honolulu := time.FixedZone("UTC-10", -10*60*60)
+	kiritimati := time.FixedZone("UTC+14", 14*60*60)
+	for _, tt := range []struct {
+		name    string
+		now     time.Time
+		expired bool
+	}{
+		// 2026-03-11 09:30 UTC, still the expiry date locally
+		{"last local day west of UTC", time.Date(2026, 3, 10, 23, 30, 0, 0, honolulu), false},
+		{"day after west of UTC", time.Date(2026, 3, 11, 0, 30, 0, 0, honolulu), true},
+		// 2026-03-10 10:30 UTC, already the next day locally
+		{"day after east of UTC", time.Date(2026, 3, 11, 0, 30, 0, 0, kiritimati), true},
+		{"last local day east of UTC", time.Date(2026, 3, 10, 23, 30, 0, 0, kiritimati), false},
+		{"last day in UTC", time.Date(2026, 3, 10, 23, 59, 0, 0, time.UTC), false},
+	} {
+		_, err := LoadExemptions(filename, tt.now)
+		if expired := err != nil && strings.Contains(err.Error(), "expired"); expired != tt.expired {
+			t.Errorf("%s: expired = %v, want %v (err: %v)", tt.name, expired, tt.expired, err)
+		}
+	}
+}
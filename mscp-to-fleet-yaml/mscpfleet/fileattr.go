@@ -0,0 +1,466 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// auditDirectory is the audit log directory named by audit_control's dir line
+const auditDirectory = "/var/audit"
+
+// File attributes checked by file attribute rules
+const (
+	// fileOwner requires a user ID
+	fileOwner = "owner"
+	// fileGroup requires a group ID
+	fileGroup = "group"
+	// fileMode requires exactly the permission bits of Value, including the
+	// setuid, setgid and sticky bits, as mSCP's stat and ls checks compare them
+	fileMode = "mode"
+	// filePerm prohibits having all the permission bits of Value, like find -perm -<bits>
+	filePerm = "perm"
+)
+
+// Files a file attribute rule checks
+const (
+	// fileScopeSelf checks the path itself
+	fileScopeSelf = "self"
+	// fileScopeEntries checks the entries of a directory
+	fileScopeEntries = "entries"
+	// fileScopeRecursive checks every file below a directory
+	fileScopeRecursive = "recursive"
+)
+
+// fileModeExpr converts osquery's file.mode, an octal string such as "0640",
+// to its integer value so permission bits can be masked; SQLite converts each
+// digit to a number in the arithmetic
+const fileModeExpr = "(SUBSTR(mode, -4, 1) * 512 + SUBSTR(mode, -3, 1) * 64 + SUBSTR(mode, -2, 1) * 8 + SUBSTR(mode, -1, 1))"
+
+// accountIDs are the IDs of the accounts mSCP checks name instead of numbering
+var accountIDs = map[string]int{"root": 0, "wheel": 0, "daemon": 1, "staff": 20, "admin": 80}
+
+var (
+	auditDirPattern   = regexp.MustCompile(`\$\([^()]*\^dir[^()]*audit_control[^()]*\)`)
+	awkFieldPattern   = regexp.MustCompile(`(?:print|s\s*\+=)\s*\$([34])\b`)
+	awkExcludePattern = regexp.MustCompile(`!/([^/]+)/`)
+	lsPermPattern     = regexp.MustCompile(`^[-dl][-r][-w][-xsS][-r][-w][-xsS][-r][-w][-xtT]$`)
+)
+
+// fileAttributeCheck is a required owner, group or mode for a file, the
+// entries of a directory, or every file below a directory
+type fileAttributeCheck struct {
+	Path  string
+	Scope string
+	// Type restricts the files checked, such as "directory" or "regular"
+	Type string
+	// Exclude lists file names that are not checked
+	Exclude   []string
+	Attribute string
+	// Value is the user or group ID, or the permission bits for fileMode and filePerm
+	Value int
+}
+
+// Query returns the policy query for the check. A single path must exist
+// and comply; for directory contents the query passes when no file
+// violates the requirement.
+func (c fileAttributeCheck) Query() string {
+	if c.Scope == fileScopeSelf {
+		return passIfExists(selectFrom("file", eq("path", c.Path), c.typeCondition(), c.compliant()))
+	}
+
+	var scope []string
+	if c.Scope == fileScopeRecursive {
+		// osquery expands %% to every file below the directory. The file table
+		// turns the pattern into a glob and ignores ESCAPE, so the path is not
+		// escaped; an unescaped _ still matches itself.
+		scope = append(scope, compare("path", "LIKE", strings.TrimSuffix(c.Path, "/")+"/%%"))
+	} else {
+		scope = append(scope, eq("directory", c.Path))
+	}
+	scope = append(scope, c.typeCondition())
+	for _, name := range c.Exclude {
+		scope = append(scope, notEq("filename", name))
+	}
+	return passIfNone("file", and(scope...), c.violation())
+}
+
+// typeCondition restricts the check to the configured file type
+func (c fileAttributeCheck) typeCondition() string {
+	if c.Type == "" {
+		return ""
+	}
+	return eq("type", c.Type)
+}
+
+// compliant returns the condition a complying file satisfies
+func (c fileAttributeCheck) compliant() string {
+	switch c.Attribute {
+	case fileOwner:
+		return eq("uid", c.Value)
+	case fileGroup:
+		return eq("gid", c.Value)
+	case fileMode:
+		return fileModeExpr + " = " + literal(c.Value)
+	}
+	return "(" + fileModeExpr + " & " + literal(c.Value) + ") != " + literal(c.Value)
+}
+
+// violation returns the condition a file violating the requirement satisfies
+func (c fileAttributeCheck) violation() string {
+	switch c.Attribute {
+	case fileOwner:
+		return notEq("uid", c.Value)
+	case fileGroup:
+		return notEq("gid", c.Value)
+	case fileMode:
+		return fileModeExpr + " != " + literal(c.Value)
+	}
+	return "(" + fileModeExpr + " & " + literal(c.Value) + ") = " + literal(c.Value)
+}
+
+// parseFileAttributeCheck reads the file attribute a rule's check script
+// tests. It understands the mSCP idioms
+//
+//	stat -f %A|%Lp|%u|%Su|%g|%Sg <path>
+//	ls -dn <path> | awk '{print $3}'             (owner of a directory)
+//	ls -n <dir> | awk '{s+=$3} END {print s}'     (owners of its entries)
+//	ls -l <dir> | awk '!/-r--r-----|current|total/{print $1}' | wc -l
+//	find <dir> [-maxdepth 1] [-type d] -perm -2 | wc -l
+//
+// where the audit log directory may be read from audit_control.
+func parseFileAttributeCheck(rule *Rule) (fileAttributeCheck, bool) {
+	script := auditDirPattern.ReplaceAllString(strings.TrimSpace(rule.Check), auditDirectory)
+	if strings.Contains(script, "\n") {
+		return fileAttributeCheck{}, false
+	}
+	segments := splitPipeline(script)
+	fields := shellFields(segments[0])
+	if len(fields) < 2 {
+		return fileAttributeCheck{}, false
+	}
+	expected, ok := expectedResult(rule.Result)
+	if !ok {
+		return fileAttributeCheck{}, false
+	}
+
+	switch path.Base(fields[0]) {
+	case "stat":
+		return parseStatCheck(fields[1:], expected)
+	case "ls":
+		return parseLsCheck(fields[1:], segments[1:], expected)
+	case "find":
+		return parseFindCheck(fields[1:], segments[1:], expected)
+	}
+	return fileAttributeCheck{}, false
+}
+
+// parseStatCheck handles stat -f <format> <path>
+func parseStatCheck(args []string, expected interface{}) (fileAttributeCheck, bool) {
+	check := fileAttributeCheck{Scope: fileScopeSelf}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-f" && i+1 < len(args):
+			i++
+			switch strings.TrimPrefix(args[i], "%") {
+			case "A", "Lp", "OLp":
+				check.Attribute = fileMode
+			case "u", "Su":
+				check.Attribute = fileOwner
+			case "g", "Sg":
+				check.Attribute = fileGroup
+			default:
+				return fileAttributeCheck{}, false
+			}
+		case strings.HasPrefix(args[i], "/") && check.Path == "":
+			check.Path = args[i]
+		case strings.HasPrefix(args[i], "-"):
+		default:
+			return fileAttributeCheck{}, false
+		}
+	}
+	if check.Attribute == "" || check.Path == "" {
+		return fileAttributeCheck{}, false
+	}
+	value, ok := attributeValue(check.Attribute, expected)
+	if !ok {
+		return fileAttributeCheck{}, false
+	}
+	check.Value = value
+	return check, true
+}
+
+// parseLsCheck handles ls listings summed, printed or filtered by awk
+func parseLsCheck(args, pipeline []string, expected interface{}) (fileAttributeCheck, bool) {
+	check := fileAttributeCheck{Scope: fileScopeEntries}
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "-"):
+			if strings.Contains(arg, "d") {
+				check.Scope = fileScopeSelf
+			}
+			if strings.Contains(arg, "R") {
+				check.Scope = fileScopeRecursive
+			}
+		case strings.HasPrefix(arg, "/") && check.Path == "":
+			check.Path = arg
+		default:
+			return fileAttributeCheck{}, false
+		}
+	}
+	if check.Path == "" || len(pipeline) == 0 {
+		return fileAttributeCheck{}, false
+	}
+
+	awk := pipeline[0]
+	if m := awkFieldPattern.FindStringSubmatch(awk); m != nil {
+		check.Attribute = fileOwner
+		if m[1] == "4" {
+			check.Attribute = fileGroup
+		}
+		summed := strings.Contains(awk, "+=")
+		if summed != (check.Scope != fileScopeSelf) {
+			return fileAttributeCheck{}, false
+		}
+		if summed {
+			// A sum of IDs is zero only when every ID is zero
+			if expected != 0 {
+				return fileAttributeCheck{}, false
+			}
+			check.Value = 0
+			return check, true
+		}
+		value, ok := attributeValue(check.Attribute, expected)
+		if !ok {
+			return fileAttributeCheck{}, false
+		}
+		check.Value = value
+		return check, true
+	}
+
+	// Files whose listing does not match the required permissions are counted
+	m := awkExcludePattern.FindStringSubmatch(awk)
+	if m == nil || check.Scope == fileScopeSelf || expected != 0 || !countsLines(pipeline[1:]) {
+		return fileAttributeCheck{}, false
+	}
+	check.Attribute = fileMode
+	mode := -1
+	for _, alternative := range strings.Split(m[1], "|") {
+		switch {
+		case lsPermPattern.MatchString(alternative):
+			mode = lsPermBits(alternative)
+		case alternative == "total":
+		case isFileName(alternative):
+			check.Exclude = append(check.Exclude, alternative)
+		default:
+			return fileAttributeCheck{}, false
+		}
+	}
+	if mode < 0 {
+		return fileAttributeCheck{}, false
+	}
+	check.Value = mode
+	return check, true
+}
+
+// parseFindCheck handles find <dir> ... -perm -<bits> | wc -l
+func parseFindCheck(args, pipeline []string, expected interface{}) (fileAttributeCheck, bool) {
+	if expected != 0 || !countsLines(pipeline) || !strings.HasPrefix(args[0], "/") {
+		return fileAttributeCheck{}, false
+	}
+	check := fileAttributeCheck{Path: args[0], Scope: fileScopeRecursive}
+	for i := 1; i < len(args); i++ {
+		if i+1 >= len(args) {
+			if args[i] == "-ls" || args[i] == "-print" {
+				continue
+			}
+			return fileAttributeCheck{}, false
+		}
+		switch args[i] {
+		case "-maxdepth":
+			if args[i+1] != "1" {
+				return fileAttributeCheck{}, false
+			}
+			check.Scope = fileScopeEntries
+		case "-type":
+			switch args[i+1] {
+			case "d":
+				check.Type = "directory"
+			case "f":
+				check.Type = "regular"
+			default:
+				return fileAttributeCheck{}, false
+			}
+		case "-perm":
+			bits, ok := findPermBits(args[i+1])
+			if !ok {
+				return fileAttributeCheck{}, false
+			}
+			check.Attribute, check.Value = filePerm, bits
+		case "-xdev", "-x":
+			continue
+		default:
+			return fileAttributeCheck{}, false
+		}
+		i++
+	}
+	if check.Attribute == "" {
+		return fileAttributeCheck{}, false
+	}
+	return check, true
+}
+
+// findPermBits parses find's "all of these bits" permission forms, -2,
+// -0002 and -o+w
+func findPermBits(perm string) (int, bool) {
+	if !strings.HasPrefix(perm, "-") {
+		return 0, false
+	}
+	perm = perm[1:]
+	if bits, err := strconv.ParseUint(perm, 8, 16); err == nil {
+		return int(bits), true
+	}
+	who, what, ok := strings.Cut(perm, "+")
+	if !ok || who == "" || what == "" {
+		return 0, false
+	}
+	bits := 0
+	for _, w := range who {
+		shift := map[rune]int{'u': 6, 'g': 3, 'o': 0}
+		s, known := shift[w]
+		if !known {
+			return 0, false
+		}
+		for _, p := range what {
+			bit := map[rune]int{'r': 4, 'w': 2, 'x': 1}[p]
+			if bit == 0 {
+				return 0, false
+			}
+			bits |= bit << s
+		}
+	}
+	return bits, true
+}
+
+// lsPermBits converts an ls permission string such as -r--r----- or
+// -rwsr-xr-x to its mode bits. s and S in an execute position set setuid or
+// setgid, t and T set the sticky bit; the lowercase forms are also executable.
+func lsPermBits(perm string) int {
+	special := map[int]int{2: 04000, 5: 02000, 8: 01000}
+	bits := 0
+	for i, c := range perm[1:] {
+		switch c {
+		case '-':
+		case 's', 't':
+			bits |= 1<<(8-i) | special[i]
+		case 'S', 'T':
+			bits |= special[i]
+		default:
+			bits |= 1 << (8 - i)
+		}
+	}
+	return bits
+}
+
+// attributeValue converts a check's expected result to a user or group ID,
+// or to mode bits from octal digits such as 700
+func attributeValue(attribute string, expected interface{}) (int, bool) {
+	text := fmt.Sprint(expected)
+	if attribute == fileMode {
+		bits, err := strconv.ParseUint(text, 8, 16)
+		return int(bits), err == nil
+	}
+	if id, ok := accountIDs[text]; ok {
+		return id, true
+	}
+	id, err := strconv.Atoi(text)
+	return id, err == nil && id >= 0
+}
+
+// countsLines reports whether a pipeline counts lines with wc -l, optionally
+// followed by tr or xargs to strip the padding
+func countsLines(pipeline []string) bool {
+	for i, segment := range pipeline {
+		fields := shellFields(segment)
+		if len(fields) < 2 || path.Base(fields[0]) != "wc" || fields[1] != "-l" {
+			continue
+		}
+		for _, rest := range pipeline[i+1:] {
+			if f := shellFields(rest); len(f) == 0 || (path.Base(f[0]) != "tr" && path.Base(f[0]) != "xargs") {
+				return false
+			}
+		}
+		return i == 0
+	}
+	return false
+}
+
+// isFileName reports whether s is a plain file name
+func isFileName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "/ *?[]().^$\\")
+}
+
+// splitPipeline splits a command at the pipes outside quotes
+func splitPipeline(command string) []string {
+	var segments []string
+	var quote rune
+	start := 0
+	for i, c := range command {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '|':
+			segments = append(segments, command[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, command[start:])
+}
+
+// shellFields splits a command into words, removing quotes
+func shellFields(command string) []string {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	inWord := false
+	for _, c := range command {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				fields = append(fields, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// fileAttributeQuery builds the query for rules whose check reads file ownership or permissions
+func fileAttributeQuery(rule *Rule) (string, bool) {
+	check, ok := parseFileAttributeCheck(rule)
+	if !ok {
+		return "", false
+	}
+	return check.Query(), true
+}
@@ -0,0 +1,87 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// gitOpsPolicy is a policy as written in a Fleet GitOps team file
+type gitOpsPolicy struct {
+	Name             string   `yaml:"name"`
+	Description      string   `yaml:"description"`
+	Resolution       string   `yaml:"resolution"`
+	Query            string   `yaml:"query"`
+	Platform         string   `yaml:"platform"`
+	Critical         bool     `yaml:"critical"`
+	LabelsIncludeAny []string `yaml:"labels_include_any,omitempty"`
+}
+
+// newGitOpsPolicy converts a Fleet policy to the GitOps team file format
+func newGitOpsPolicy(policy *FleetPolicy) gitOpsPolicy {
+	return gitOpsPolicy{
+		Name:             policy.Spec.Name,
+		Description:      policy.Spec.Description,
+		Resolution:       policy.Spec.Resolution,
+		Query:            policy.Spec.Query,
+		Platform:         policy.Spec.Platform,
+		Critical:         policy.Spec.Critical,
+		LabelsIncludeAny: policy.Spec.LabelsIncludeAny,
+	}
+}
+
+// MarshalGitOpsTeam renders the policies of the given baselines as a Fleet
+// GitOps team file. The policies of each baseline are preceded by a comment
+// naming the baseline and its provenance. Fleet requires policy names to be
+// unique within a team, so baselines that share rules must be merged first.
+func MarshalGitOpsTeam(team string, baselines []*BaselineResult, sourceVersion string) ([]byte, error) {
+	policies := &yaml.Node{Kind: yaml.SequenceNode}
+	seen := map[string]string{}
+	for _, baseline := range baselines {
+		for i := range baseline.Policies {
+			name := baseline.Policies[i].Spec.Name
+			if other, ok := seen[name]; ok {
+				return nil, fmt.Errorf("%w: policy name %q is used twice, in %s and %s; merge the baselines for a GitOps team file", ErrInput, name, other, baseline.Name)
+			}
+			seen[name] = baseline.Name
+			item := &yaml.Node{}
+			if err := item.Encode(newGitOpsPolicy(&baseline.Policies[i])); err != nil {
+				return nil, fmt.Errorf("failed to marshal policy %q: %w", baseline.Policies[i].Spec.Name, err)
+			}
+			if i == 0 {
+				item.HeadComment = gitOpsComment(baseline)
+			}
+			policies.Content = append(policies.Content, item)
+		}
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	doc.Content = append(doc.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "name"},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: team},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "policies"},
+		policies,
+	)
+	body, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal team %s: %w", team, err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Fleet GitOps policies for team %s\n", team)
+	sb.WriteString(generatedHeader)
+	fmt.Fprintf(&sb, "# mSCP source: %s\n", sourceVersion)
+	fmt.Fprintf(&sb, "# Content hash: %s\n\n", contentHash(body))
+	sb.Write(body)
+	return []byte(sb.String()), nil
+}
+
+// gitOpsComment returns the comment placed above the first policy of a baseline
+func gitOpsComment(baseline *BaselineResult) string {
+	lines := []string{fmt.Sprintf("# %s (%s)", baseline.Title, baseline.Name)}
+	for _, line := range baseline.Info.CommentLines() {
+		lines = append(lines, strings.TrimRight("# "+line, " "))
+	}
+	return strings.Join(lines, "\n")
+}
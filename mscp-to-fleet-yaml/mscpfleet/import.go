@@ -0,0 +1,219 @@
+package mscpfleet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportedPolicy is a policy read from Fleet, in any of the supported formats.
+// Path is set for GitOps entries that reference another file.
+type ImportedPolicy struct {
+	Name        string `yaml:"name" json:"name"`
+	Query       string `yaml:"query" json:"query"`
+	Description string `yaml:"description" json:"description"`
+	Path        string `yaml:"path" json:"-"`
+}
+
+// importDocument is a YAML document in fleetctl apply or GitOps team format
+type importDocument struct {
+	Kind     string           `yaml:"kind"`
+	Spec     ImportedPolicy   `yaml:"spec"`
+	Policies []ImportedPolicy `yaml:"policies"`
+}
+
+// LoadImportedPolicies reads policies from a fleetctl apply file, a GitOps
+// team or library file, or a Fleet API JSON export
+func LoadImportedPolicies(filename string) ([]ImportedPolicy, error) {
+	return loadImportedPolicies(filename, map[string]bool{})
+}
+
+// loadImportedPolicies reads the policies of a file and of the files it
+// includes. A file included a second time, such as through an include cycle,
+// is an error.
+func loadImportedPolicies(filename string, visited map[string]bool) ([]ImportedPolicy, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if visited[abs] {
+		return nil, fmt.Errorf("%s is included more than once", filename)
+	}
+	visited[abs] = true
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if strings.HasSuffix(filename, ".json") || bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		return parseAPIExport(filename, trimmed)
+	}
+
+	var policies []ImportedPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+
+		var entries []ImportedPolicy
+		switch node.Content[0].Kind {
+		case yaml.SequenceNode:
+			// GitOps library file: a list of policies
+			if err := node.Decode(&entries); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+			}
+		case yaml.MappingNode:
+			var doc importDocument
+			if err := node.Decode(&doc); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+			}
+			if doc.Kind == "policy" {
+				entries = []ImportedPolicy{doc.Spec}
+			} else {
+				entries = doc.Policies
+			}
+		}
+
+		for _, entry := range entries {
+			if entry.Path == "" {
+				policies = append(policies, entry)
+				continue
+			}
+			// GitOps team files reference library files relative to themselves
+			referenced, err := loadImportedPolicies(filepath.Join(filepath.Dir(filename), entry.Path), visited)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s referenced from %s: %w", entry.Path, filename, err)
+			}
+			policies = append(policies, referenced...)
+		}
+	}
+	return policies, nil
+}
+
+// parseAPIExport parses a Fleet API policy listing or a bare JSON array of policies
+func parseAPIExport(filename string, data []byte) ([]ImportedPolicy, error) {
+	var listing struct {
+		Policies []ImportedPolicy `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &listing); err == nil && listing.Policies != nil {
+		return listing.Policies, nil
+	}
+
+	var policies []ImportedPolicy
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse %s as a Fleet API export: %w", filename, err)
+	}
+	return policies, nil
+}
+
+// RuleMatcher matches Fleet policies back to the mSCP rules they were generated from
+type RuleMatcher struct {
+	rules  map[string]*Rule
+	titles []titleMatcher
+}
+
+// titleMatcher matches policy names against a rule title, with $ODV as a wildcard
+type titleMatcher struct {
+	pattern *regexp.Regexp
+	ruleID  string
+}
+
+var ruleIDTokenPattern = regexp.MustCompile(`[a-z0-9_]+`)
+
+// NewRuleMatcher indexes every rule in an mSCP source
+func NewRuleMatcher(source *Source) (*RuleMatcher, error) {
+	rm := &RuleMatcher{rules: map[string]*Rule{}}
+	err := fs.WalkDir(source.FS, "rules", func(rulePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(rulePath) != ".yaml" {
+			return nil
+		}
+		var rule Rule
+		if err := loadYAMLFS(source.FS, rulePath, &rule); err != nil {
+			return fmt.Errorf("failed to load rule %s: %w", rulePath, err)
+		}
+		if rule.ID == "" {
+			return nil
+		}
+		rm.rules[rule.ID] = &rule
+		if rule.Title != "" {
+			// Names from custom templates may put text such as a CIS section before the title
+			expr := `(?i)(?:^|[\s\]])` + strings.ReplaceAll(regexp.QuoteMeta(rule.Title), `\$ODV`, ".+?") + "$"
+			if pattern, err := regexp.Compile(expr); err == nil {
+				rm.titles = append(rm.titles, titleMatcher{pattern: pattern, ruleID: rule.ID})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
+
+// Match returns the rule a policy was generated from. A rule ID in the policy
+// name wins; otherwise the name, without the generated prefix and any section
+// prefix, must end with exactly one rule title.
+func (rm *RuleMatcher) Match(policyName string) (*Rule, error) {
+	for _, token := range ruleIDTokenPattern.FindAllString(strings.ToLower(policyName), -1) {
+		if rule, ok := rm.rules[token]; ok {
+			return rule, nil
+		}
+	}
+
+	title := trimSectionPrefix(strings.TrimSpace(strings.TrimPrefix(policyName, policyNamePrefix)))
+	var matches []string
+	for _, tm := range rm.titles {
+		if tm.pattern.MatchString(title) {
+			matches = append(matches, tm.ruleID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return rm.rules[matches[0]], nil
+	}
+	return nil, fmt.Errorf("policy %q matches several rules: %s", policyName, strings.Join(matches, ", "))
+}
+
+// matchesGenerated reports whether query is what the converter generates for
+// the rule under any of its organization defined values and posture modes
+func matchesGenerated(rule *Rule, query string) bool {
+	values := []interface{}{nil}
+	for _, baseline := range sortedKeys(rule.ODV) {
+		values = append(values, rule.ODV[baseline])
+	}
+	for _, value := range values {
+		for _, posture := range []string{PostureState, PostureProfile, PostureBoth} {
+			generated := CreateFleetPolicy(rule.WithODV(value), "", PolicyOptions{Posture: posture})
+			if generated != nil && normalizeQuery(unscopeQuery(generated.Spec.Query)) == normalizeQuery(query) {
+				return true
+			}
+		}
+	}
+	return false
+}
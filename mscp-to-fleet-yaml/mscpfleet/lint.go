@@ -0,0 +1,143 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// LintIssue describes a policy query that cannot express non-compliance
+type LintIssue struct {
+	Policy  string
+	Rule    string
+	Message string
+}
+
+// lintRule checks a normalized query and returns a message if it is flagged
+type lintRule struct {
+	name  string
+	check func(query string) string
+}
+
+var (
+	lintFromPattern       = regexp.MustCompile(`(?i)\bFROM\s+(\w+)`)
+	lintWherePattern      = regexp.MustCompile(`(?i)\b(WHERE|HAVING)\b`)
+	lintPathOnlyPattern   = regexp.MustCompile(`(?i)^SELECT 1 FROM file WHERE \(?path (LIKE|=) '[^']*'\)?( (OR|AND) \(?path (LIKE|=) '[^']*'\)?)*;?$`)
+	lintAnyFilePattern    = regexp.MustCompile(`(?i)^SELECT 1 FROM file WHERE .*path LIKE '[^']*%'.*\b(uid|gid|mode)\s*(=|<|>|<=|>=|!=)`)
+	lintDomainOnlyPattern = regexp.MustCompile(`(?i)^SELECT 1 FROM managed_policies( WHERE domain\s*=\s*'[^']*')?;?$`)
+	lintProhibitedPattern = regexp.MustCompile(`(?i)\bacl\b`)
+	lintNotExistsPattern  = regexp.MustCompile(`(?i)\bNOT\s+EXISTS\b`)
+	lintExistsPattern     = regexp.MustCompile(`(?i)^SELECT 1 WHERE EXISTS \((.*)\);?$`)
+)
+
+// lintRules are the query shapes flagged by LintQuery
+var lintRules = []lintRule{
+	{"no-table", func(q string) string {
+		if !lintFromPattern.MatchString(q) {
+			return "selects no table, so it returns a row on every host"
+		}
+		return ""
+	}},
+	{"unfiltered-table", func(q string) string {
+		if m := lintFromPattern.FindStringSubmatch(q); m != nil && !lintWherePattern.MatchString(q) {
+			return fmt.Sprintf("has no WHERE clause, so it passes whenever %s returns any row", m[1])
+		}
+		return ""
+	}},
+	{"path-only", func(q string) string {
+		if lintPathOnlyPattern.MatchString(q) {
+			return "only filters on path, so it passes whenever a matching file exists"
+		}
+		return ""
+	}},
+	{"any-file", func(q string) string {
+		if lintAnyFilePattern.MatchString(q) {
+			return "passes when any single file complies; use an aggregate or NOT EXISTS so every file must comply"
+		}
+		return ""
+	}},
+	{"domain-only", func(q string) string {
+		if lintDomainOnlyPattern.MatchString(q) {
+			return "only checks that a managed profile domain exists, not the setting it requires"
+		}
+		return ""
+	}},
+	{"prohibited-state", func(q string) string {
+		if lintProhibitedPattern.MatchString(q) && !lintNotExistsPattern.MatchString(q) {
+			return "selects the prohibited state, so it passes only when the host is non-compliant; use NOT EXISTS"
+		}
+		return ""
+	}},
+}
+
+// LintQuery returns issues for query shapes that cannot express failure
+func LintQuery(policyName, query string) []LintIssue {
+	// Lint the underlying check rather than the OS scope and EXISTS wrappers
+	normalized := unscopeQuery(strings.Join(strings.Fields(query), " "))
+	if m := lintExistsPattern.FindStringSubmatch(normalized); m != nil && balancedParens(m[1]) {
+		normalized = m[1]
+	}
+
+	var issues []LintIssue
+	if err := ParseSQL(query); err != nil {
+		issues = append(issues, LintIssue{Policy: policyName, Rule: "invalid-sql", Message: fmt.Sprintf("does not parse: %v", err)})
+	}
+	for _, rule := range lintRules {
+		if message := rule.check(normalized); message != "" {
+			issues = append(issues, LintIssue{Policy: policyName, Rule: rule.name, Message: message})
+		}
+	}
+	return issues
+}
+
+// balancedParens reports whether every parenthesis in query is closed in order
+func balancedParens(query string) bool {
+	depth := 0
+	for _, c := range query {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// ValidatePolicy checks that a generated policy has the fields Fleet needs
+// and lints its query. Unmapped queries are reported as mapping gaps instead.
+func ValidatePolicy(policy *FleetPolicy) []LintIssue {
+	var issues []LintIssue
+	name := policy.Spec.Name
+	if strings.TrimSpace(name) == "" {
+		issues = append(issues, LintIssue{Policy: name, Rule: "missing-name", Message: "has no name"})
+	}
+	if length := utf8.RuneCountInString(name); length > MaxPolicyNameLength {
+		issues = append(issues, LintIssue{Policy: name, Rule: "name-too-long", Message: fmt.Sprintf("name has %d characters, Fleet allows %d", length, MaxPolicyNameLength)})
+	}
+	switch {
+	case strings.TrimSpace(policy.Spec.Query) == "":
+		issues = append(issues, LintIssue{Policy: name, Rule: "missing-query", Message: "has no query"})
+	case !isUnmappedQuery(policy.Spec.Query):
+		issues = append(issues, LintQuery(name, policy.Spec.Query)...)
+	}
+	return issues
+}
+
+// LintPolicyFile lints every policy in a Fleet policy file
+func LintPolicyFile(filePath string) ([]LintIssue, error) {
+	policies, err := LoadPolicies(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	for _, policy := range policies {
+		issues = append(issues, LintQuery(policy.Spec.Name, policy.Spec.Query)...)
+	}
+	return issues, nil
+}
@@ -0,0 +1,316 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Collision is a policy name generated for different rules or queries
+type Collision struct {
+	Name      string
+	RuleIDs   []string
+	Baselines []string
+}
+
+// CollisionError is returned when merged policies would share a name but not a rule and query
+type CollisionError struct {
+	Collisions []Collision
+}
+
+// Error lists the colliding names with their rules and baselines
+func (e *CollisionError) Error() string {
+	names := make([]string, 0, len(e.Collisions))
+	for _, c := range e.Collisions {
+		names = append(names, fmt.Sprintf("%q (rules %s in %s)", c.Name, strings.Join(c.RuleIDs, ", "), strings.Join(c.Baselines, ", ")))
+	}
+	return fmt.Sprintf("%v: %d policy names are used for different rules or queries: %s", ErrValidation, len(e.Collisions), strings.Join(names, "; "))
+}
+
+// Unwrap makes collisions validation errors
+func (e *CollisionError) Unwrap() error {
+	return ErrValidation
+}
+
+// mergedPolicy is one policy of a merged result and the baselines it came from
+type mergedPolicy struct {
+	policy    FleetPolicy
+	ruleID    string
+	section   string
+	baselines []string
+	sources   []mergeSource
+}
+
+// mergeSource is the policy one baseline contributes to a merged policy.
+// converted is nil when the baseline result was not built by a converter.
+type mergeSource struct {
+	baseline  string
+	policy    FleetPolicy
+	converted *ConvertedRule
+}
+
+// MergeBaselines merges the policies of several baselines into one result
+// with a single policy per rule. The tags of merged policies are the union of
+// the baselines' tags, so each keeps a tag per baseline and the references of
+// all of them, and {baseline} in their names lists every baseline they came
+// from. Policies that would share a name but not a rule and query are
+// returned as a *CollisionError, so a rule whose query differs between
+// baselines, for example through its organization defined value, refuses the
+// merge unless the name template tells the variants apart; the rule is then
+// kept once per distinct query. Baselines that disagree on a policy's
+// severity, criticality or exemption are returned as warnings: the merged
+// policy takes the highest severity, is critical when any baseline enforcing
+// it marks it critical, and is exempted only when every baseline exempts it.
+func MergeBaselines(name, title string, baselines []*BaselineResult) (*BaselineResult, []Warning, error) {
+	merged, warnings := collectPolicies(baselines)
+	if collisions := findCollisions(merged); len(collisions) > 0 {
+		return nil, nil, &CollisionError{Collisions: collisions}
+	}
+
+	names := make([]string, 0, len(baselines))
+	for _, baseline := range baselines {
+		names = append(names, baseline.Name)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].ruleID < merged[j].ruleID })
+	result := &BaselineResult{
+		Name:  name,
+		Title: title,
+		Info:  BaselineInfo{Description: "Policies merged from the " + strings.Join(names, ", ") + " baselines.", Merged: names},
+	}
+	for _, mp := range merged {
+		sort.Strings(mp.policy.Spec.Tags)
+		result.Policies = append(result.Policies, mp.policy)
+		result.Sections = append(result.Sections, mp.section)
+		result.RuleIDs = append(result.RuleIDs, mp.ruleID)
+	}
+	return result, warnings, nil
+}
+
+// FindCollisions returns every policy name the baselines use for more than
+// one rule or query. Identical policies in several baselines are not collisions.
+func FindCollisions(baselines []*BaselineResult) []Collision {
+	merged, _ := collectPolicies(baselines)
+	return findCollisions(merged)
+}
+
+// collectPolicies combines the policies of the baselines by rule, query and
+// description, and resolves each combined policy from its sources
+func collectPolicies(baselines []*BaselineResult) ([]*mergedPolicy, []Warning) {
+	var merged []*mergedPolicy
+	var names *NameTemplate
+	byQuery := map[string]*mergedPolicy{}
+	for _, baseline := range baselines {
+		if names == nil {
+			names = baseline.names
+		}
+		for i := range baseline.Policies {
+			source := mergeSource{baseline: baseline.Name, policy: baseline.Policies[i]}
+			if i < len(baseline.converted) {
+				source.converted = baseline.converted[i]
+			}
+			ruleID := source.policy.Spec.Name
+			if i < len(baseline.RuleIDs) {
+				ruleID = baseline.RuleIDs[i]
+			}
+			// Descriptions carry the organization defined value, which
+			// unmapped queries do not
+			key := ruleID + "\x00" + normalizeQuery(source.policy.Spec.Query) + "\x00" + source.description()
+			if mp, ok := byQuery[key]; ok {
+				mp.sources = append(mp.sources, source)
+				mp.baselines = append(mp.baselines, baseline.Name)
+				continue
+			}
+			mp := &mergedPolicy{ruleID: ruleID, baselines: []string{baseline.Name}, sources: []mergeSource{source}}
+			if i < len(baseline.Sections) {
+				mp.section = baseline.Sections[i]
+			}
+			byQuery[key] = mp
+			merged = append(merged, mp)
+		}
+	}
+
+	var warnings []Warning
+	for _, mp := range merged {
+		warnings = append(warnings, mp.resolve(names)...)
+	}
+	return merged, warnings
+}
+
+// description returns the source policy's description without its exemption annotation
+func (src mergeSource) description() string {
+	if src.converted == nil || src.converted.Exemption == nil {
+		return src.policy.Spec.Description
+	}
+	return strings.TrimSpace(strings.TrimPrefix(src.policy.Spec.Description, src.converted.Exemption.Annotation()))
+}
+
+// exempted reports whether the source policy was annotated as exempted
+func (src mergeSource) exempted() bool {
+	return containsString(src.policy.Spec.Tags, exemptedTag)
+}
+
+// severity returns the severity the source policy is tagged with
+func (src mergeSource) severity() string {
+	for _, tag := range src.policy.Spec.Tags {
+		if strings.HasPrefix(tag, severityTagPrefix) {
+			return strings.TrimPrefix(tag, severityTagPrefix)
+		}
+	}
+	return ""
+}
+
+// severityRank orders severities from most to least severe
+var severityRank = []string{SeverityHigh, SeverityMedium, SeverityLow}
+
+// resolve builds the merged policy from its sources: tags and labels are
+// joined, the highest severity is kept, exemption and criticality follow the
+// baselines that enforce the policy, and the name is rendered for every
+// source baseline. It returns a warning for each disagreement it resolved.
+func (mp *mergedPolicy) resolve(names *NameTemplate) []Warning {
+	first := mp.sources[0]
+	policy := first.policy
+	policy.Spec.Description = first.description()
+	policy.Spec.Tags = []string{}
+	policy.Spec.LabelsIncludeAny = nil
+
+	var exempted, enforced, critical, notCritical, annotations []string
+	bySeverity := map[string][]string{}
+	converted := true
+	for _, src := range mp.sources {
+		for _, tag := range src.policy.Spec.Tags {
+			if tag != exemptedTag && !strings.HasPrefix(tag, severityTagPrefix) {
+				policy.Spec.Tags = unionStrings(policy.Spec.Tags, []string{tag})
+			}
+		}
+		policy.Spec.LabelsIncludeAny = unionStrings(policy.Spec.LabelsIncludeAny, src.policy.Spec.LabelsIncludeAny)
+		if severity := src.severity(); severity != "" {
+			bySeverity[severity] = append(bySeverity[severity], src.baseline)
+		}
+		if src.converted == nil {
+			converted = false
+		}
+
+		if src.exempted() {
+			exempted = append(exempted, src.baseline)
+			if src.converted != nil && src.converted.Exemption != nil {
+				annotations = unionStrings(annotations, []string{src.converted.Exemption.Annotation()})
+			}
+			continue
+		}
+		enforced = append(enforced, src.baseline)
+		if src.policy.Spec.Critical {
+			critical = append(critical, src.baseline)
+		} else {
+			notCritical = append(notCritical, src.baseline)
+		}
+	}
+
+	var warnings []Warning
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, Warning{Message: fmt.Sprintf(format, args...), RuleID: mp.ruleID})
+	}
+
+	severity := ""
+	var severities []string
+	for _, candidate := range severityRank {
+		if baselines, ok := bySeverity[candidate]; ok {
+			if severity == "" {
+				severity = candidate
+			}
+			severities = append(severities, fmt.Sprintf("%s in %s", candidate, strings.Join(baselines, ", ")))
+		}
+	}
+	if severity != "" {
+		policy.Spec.Tags = append(policy.Spec.Tags, severityTagPrefix+severity)
+	}
+	if len(severities) > 1 {
+		warn("merged baselines disagree on severity (%s); the merged policy is %s", strings.Join(severities, "; "), severity)
+	}
+
+	switch {
+	case len(enforced) == 0:
+		policy.Spec.Critical = false
+		if len(annotations) > 0 {
+			policy.Spec.Description = strings.TrimSpace(strings.Join(annotations, "\n\n") + "\n\n" + policy.Spec.Description)
+		}
+		policy.Spec.Tags = append(policy.Spec.Tags, exemptedTag)
+	default:
+		policy.Spec.Critical = len(critical) > 0
+		if len(critical) > 0 && len(notCritical) > 0 {
+			warn("critical in %s but not in %s; the merged policy is critical", strings.Join(critical, ", "), strings.Join(notCritical, ", "))
+		}
+		if len(exempted) > 0 {
+			warn("exempted in %s but required by %s; the merged policy is not exempted", strings.Join(exempted, ", "), strings.Join(enforced, ", "))
+		}
+	}
+
+	if names != nil && converted {
+		vars := first.converted.NameVars
+		vars.Baseline = strings.Join(mp.baselines, ", ")
+		vars.Severity = severity
+		policy.Spec.Name = names.Render(vars)
+	}
+	for i := range warnings {
+		warnings[i].Policy = policy.Spec.Name
+	}
+	mp.policy = policy
+	return warnings
+}
+
+// findCollisions lists every policy name used by more than one combined policy
+func findCollisions(merged []*mergedPolicy) []Collision {
+	byName := map[string][]*mergedPolicy{}
+	var order []string
+	for _, mp := range merged {
+		if _, ok := byName[mp.policy.Spec.Name]; !ok {
+			order = append(order, mp.policy.Spec.Name)
+		}
+		byName[mp.policy.Spec.Name] = append(byName[mp.policy.Spec.Name], mp)
+	}
+
+	var collisions []Collision
+	for _, name := range order {
+		group := byName[name]
+		if len(group) < 2 {
+			continue
+		}
+		collision := Collision{Name: name}
+		for _, mp := range group {
+			collision.RuleIDs = unionStrings(collision.RuleIDs, []string{mp.ruleID})
+			collision.Baselines = unionStrings(collision.Baselines, mp.baselines)
+		}
+		collisions = append(collisions, collision)
+	}
+	return collisions
+}
+
+// unionStrings returns a followed by the values of b it does not contain
+func unionStrings(a, b []string) []string {
+	for _, value := range b {
+		if !containsString(a, value) {
+			a = append(a, value)
+		}
+	}
+	return a
+}
+
+// Merge replaces the baselines of a result with a single merged baseline
+// named name (see MergeBaselines)
+func (r *Result) Merge(name string) error {
+	names := make([]string, 0, len(r.Baselines))
+	for _, baseline := range r.Baselines {
+		names = append(names, baseline.Name)
+	}
+	merged, warnings, err := MergeBaselines(name, "Merged baselines: "+strings.Join(names, ", "), r.Baselines)
+	if err != nil {
+		return err
+	}
+	if r.Diagnostics != nil {
+		for _, w := range warnings {
+			w.Baseline = name
+			r.Diagnostics.Warn(w)
+		}
+	}
+	r.Baselines = []*BaselineResult{merged}
+	return nil
+}
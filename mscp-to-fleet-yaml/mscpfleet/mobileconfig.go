@@ -0,0 +1,214 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryPredicate is a single condition a compliant host must satisfy
+type queryPredicate struct {
+	Description string
+	Condition   string
+}
+
+// predicateGroup is a set of alternative predicates, any of which satisfies the group
+type predicateGroup []queryPredicate
+
+var (
+	checkSuitePattern = regexp.MustCompile(`initWithSuiteName\('([^']+)'\)`)
+	checkKeyPattern   = regexp.MustCompile(`objectForKey\('([^']+)'\)`)
+)
+
+// rulePredicates derives the predicates for a rule from its mobileconfig_info
+// and check script. Every returned group must pass; within a group any
+// predicate may pass.
+func rulePredicates(rule *Rule) []predicateGroup {
+	var groups []predicateGroup
+
+	// Profile keys are all required; a key delivered under several payload
+	// domains may be satisfied by any of them
+	byKey := map[string]predicateGroup{}
+	var keys []string
+	for _, domain := range sortedKeys(rule.MobileconfigInfo) {
+		settings, ok := rule.MobileconfigInfo[domain].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range sortedKeys(settings) {
+			predicate, ok := managedPolicyPredicate(domain, key, settings[key])
+			if !ok {
+				continue
+			}
+			if _, exists := byKey[key]; !exists {
+				keys = append(keys, key)
+			}
+			byKey[key] = append(byKey[key], predicate)
+		}
+	}
+	for _, key := range keys {
+		groups = append(groups, byKey[key])
+	}
+
+	// A key read by the check script that the profile info does not cover is
+	// checked only when it is the one key the script reads, so the rule's
+	// result is its expected value
+	if keys := uncoveredCheckKeys(rule); len(keys) == 1 && len(checkKeyPattern.FindAllString(rule.Check, -1)) == 1 {
+		expected := interface{}(true)
+		if value, ok := expectedResult(rule.Result); ok {
+			expected = value
+		}
+		if predicate, ok := managedPolicyPredicate(keys[0].domain, keys[0].key, expected); ok {
+			groups = append(groups, predicateGroup{predicate})
+		}
+	}
+
+	return groups
+}
+
+// checkKey is a profile key a check script reads with objectForKey
+type checkKey struct {
+	domain, key string
+}
+
+func (k checkKey) String() string {
+	return k.domain + " " + k.key
+}
+
+// uncoveredCheckKeys returns the profile keys a rule's check script reads
+// that its mobileconfig_info does not cover
+func uncoveredCheckKeys(rule *Rule) []checkKey {
+	covered := map[checkKey]bool{}
+	for domain, settings := range rule.MobileconfigInfo {
+		if settings, ok := settings.(map[string]interface{}); ok {
+			for key := range settings {
+				covered[checkKey{domain, key}] = true
+			}
+		}
+	}
+	suites := checkSuitePattern.FindAllStringSubmatch(rule.Check, -1)
+	checkKeys := checkKeyPattern.FindAllStringSubmatch(rule.Check, -1)
+	var keys []checkKey
+	for i := 0; i < len(suites) && i < len(checkKeys); i++ {
+		key := checkKey{suites[i][1], checkKeys[i][1]}
+		if !covered[key] {
+			covered[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// uncheckedProfileKeys returns the keys a rule's check script reads that the
+// policy query leaves out: the script reads several keys and compares them
+// with one result, so the value each key must have is unknown
+func uncheckedProfileKeys(rule *Rule) []string {
+	if len(checkKeyPattern.FindAllString(rule.Check, -1)) < 2 {
+		return nil
+	}
+	var keys []string
+	for _, key := range uncoveredCheckKeys(rule) {
+		keys = append(keys, key.String())
+	}
+	return keys
+}
+
+// composeQuery builds a policy query that passes when every group passes
+func composeQuery(groups []predicateGroup) string {
+	var conditions []string
+	for _, group := range groups {
+		var alternatives []string
+		for _, predicate := range group {
+			alternatives = append(alternatives, predicate.Condition)
+		}
+		conditions = append(conditions, or(alternatives...))
+	}
+	return "SELECT 1 WHERE " + and(conditions...) + ";"
+}
+
+// describePredicates lists the component checks of a composed query
+func describePredicates(groups []predicateGroup) string {
+	var sb strings.Builder
+	sb.WriteString("This policy passes when all of the following are true:")
+	for _, group := range groups {
+		var alternatives []string
+		for _, predicate := range group {
+			alternatives = append(alternatives, predicate.Description)
+		}
+		sb.WriteString("\n- " + strings.Join(alternatives, ", or "))
+	}
+	return sb.String()
+}
+
+// managedPolicyQuery returns a policy query that passes when a profile key
+// has the given value
+func managedPolicyQuery(domain, key string, value interface{}) string {
+	predicate, ok := managedPolicyPredicate(domain, key, value)
+	if !ok {
+		return unmappedQuery
+	}
+	return composeQuery([]predicateGroup{{predicate}})
+}
+
+// managedPolicyPredicate builds the predicate for a single profile key
+func managedPolicyPredicate(domain, key string, value interface{}) (queryPredicate, bool) {
+	var condition, expected string
+	switch v := value.(type) {
+	case bool:
+		if v {
+			condition, expected = "("+or(eq("value", 1), eq("value", "true"))+")", "true"
+		} else {
+			condition, expected = "("+or(eq("value", 0), eq("value", "false"))+")", "false"
+		}
+	case int:
+		condition, expected = eq("CAST(value AS INTEGER)", v), strconv.Itoa(v)
+	case float64:
+		condition, expected = eq("CAST(value AS REAL)", v), fmt.Sprintf("%g", v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			condition, expected = eq("CAST(value AS INTEGER)", n), v
+		} else {
+			condition, expected = eq("value", v), fmt.Sprintf("%q", v)
+		}
+	default:
+		// Arrays and dictionaries are not reported by managed_policies as a single value
+		return queryPredicate{}, false
+	}
+
+	return queryPredicate{
+		Description: fmt.Sprintf("%s %s is %s", domain, key, expected),
+		Condition:   "EXISTS (" + selectFrom("managed_policies", eq("domain", domain), eq("name", key), condition) + ")",
+	}, true
+}
+
+// expectedResult returns the value a rule's check is expected to produce
+func expectedResult(result map[string]interface{}) (interface{}, bool) {
+	if s, ok := result["string"].(string); ok {
+		switch s {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+		return s, true
+	}
+	if n, ok := result["integer"].(int); ok {
+		return n, true
+	}
+	if n, ok := result["boolean"].(int); ok {
+		return n == 1, true
+	}
+	return nil, false
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+package mscpfleet
+
+import (
+	"io/fs"
+	"log/slog"
+)
+
+// Options configures Convert. The zero value converts every baseline with
+// the default OS scoping and one worker per CPU.
+type Options struct {
+	// Version describes the source in output headers, e.g. a release tag
+	Version string
+	// OSScope is "query", "label" or "none"; empty means "query"
+	OSScope string
+	// TextFormat is "markdown" or "plain"; empty means "markdown"
+	TextFormat string
+	// SectionPrefix puts the baseline section in front of the rule title in policy names
+	SectionPrefix bool
+	// Posture is "state", "profile" or "both"; empty means "state"
+	Posture string
+	// NameTemplate renders policy names (see NameTemplate); empty uses
+	// DefaultNameTemplate or, with SectionPrefix, SectionNameTemplate
+	NameTemplate string
+	// Team selects team-specific exemptions
+	Team       string
+	Exemptions *ExemptionSet
+	Tailorings []*Tailoring
+	Catalog    *QueryCatalog
+	// Critical selects the policies marked critical in Fleet
+	Critical CriticalRules
+	// Baselines limits conversion to the named baselines and tailorings
+	Baselines []string
+	// Merge, when set, merges the converted baselines into one named Merge
+	// with a single policy per rule (see MergeBaselines)
+	Merge string
+	// Strict stops at the first failed baseline and returns an error when
+	// rules are missing, unmapped or produce invalid policies
+	Strict  bool
+	Workers int
+	// Logger receives warnings and progress; nil discards them
+	Logger *slog.Logger
+}
+
+// Convert converts every baseline in an mSCP tree to Fleet policies without
+// writing anything. In strict mode a non-nil result is returned together with
+// the strict error so callers can still inspect the diagnostics.
+func Convert(fsys fs.FS, opts Options) (*Result, error) {
+	source, err := NewSource(fsys, "fs", opts.Version)
+	if err != nil {
+		return nil, InputError(err)
+	}
+
+	converter := NewBaselineConverter(source)
+	if opts.Logger != nil {
+		converter.Diagnostics().SetLogger(opts.Logger)
+	}
+	if opts.OSScope != "" {
+		if err := converter.SetOSScope(opts.OSScope); err != nil {
+			return nil, InputError(err)
+		}
+	}
+	if opts.TextFormat != "" {
+		if err := converter.SetTextFormat(opts.TextFormat); err != nil {
+			return nil, InputError(err)
+		}
+	}
+	if opts.Posture != "" {
+		if err := converter.SetPosture(opts.Posture); err != nil {
+			return nil, InputError(err)
+		}
+	}
+	converter.SetSectionPrefix(opts.SectionPrefix)
+	if opts.NameTemplate != "" {
+		if err := converter.SetNameTemplate(opts.NameTemplate); err != nil {
+			return nil, InputError(err)
+		}
+	}
+	converter.SetCriticalRules(opts.Critical)
+	if opts.Exemptions != nil {
+		converter.SetExemptions(opts.Exemptions, opts.Team)
+	}
+	if opts.Catalog != nil {
+		converter.SetCatalog(opts.Catalog)
+	}
+	for _, tailoring := range opts.Tailorings {
+		converter.AddTailoring(tailoring)
+	}
+	converter.SetBaselines(opts.Baselines)
+	converter.SetStrict(opts.Strict)
+	converter.SetWorkers(opts.Workers)
+
+	result, err := converter.ConvertAllBaselines()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Merge != "" {
+		if err := result.Merge(opts.Merge); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Strict {
+		return result, converter.StrictCheck()
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in policy name templates
+const (
+	// DefaultNameTemplate is the name every policy gets unless configured
+	DefaultNameTemplate = "macOS Security - {title}"
+	// SectionNameTemplate puts the baseline section in front of the title
+	SectionNameTemplate = "macOS Security - [{section}] {title}"
+)
+
+// MaxPolicyNameLength is the longest policy name Fleet stores
+const MaxPolicyNameLength = 255
+
+// nameVariables are the variables a name template may use besides {ref:<name>}
+var nameVariables = []string{"rule_id", "title", "baseline", "section", "severity", "cis_section", "cis_level"}
+
+var (
+	nameVariablePattern = regexp.MustCompile(`\{([^{}]*)\}`)
+	nameSpacePattern    = regexp.MustCompile(`\s+`)
+	cisBenchmarkPattern = regexp.MustCompile(`^\s*([0-9][0-9.]*)\s*(?:\(level\s*(\d+)\))?`)
+)
+
+// NameTemplate renders policy names from rule metadata. Variables are written
+// in braces: {rule_id}, {title}, {baseline}, {section}, {severity},
+// {cis_section}, {cis_level}, and {ref:<name>} for the first value of any
+// rule reference, e.g. {ref:800-53r5} or {ref:cis/controls v8}.
+type NameTemplate struct {
+	text string
+}
+
+// ParseNameTemplate checks that a template only uses known variables and has
+// balanced braces
+func ParseNameTemplate(text string) (*NameTemplate, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("name template is empty")
+	}
+	for _, m := range nameVariablePattern.FindAllStringSubmatch(text, -1) {
+		variable := m[1]
+		if strings.HasPrefix(variable, "ref:") && len(variable) > len("ref:") {
+			continue
+		}
+		if !containsString(nameVariables, variable) {
+			return nil, fmt.Errorf("name template %q uses unknown variable {%s} (use %s or ref:<name>)", text, variable, strings.Join(nameVariables, ", "))
+		}
+	}
+	if rest := nameVariablePattern.ReplaceAllString(text, ""); strings.ContainsAny(rest, "{}") {
+		return nil, fmt.Errorf("name template %q has unbalanced braces", text)
+	}
+	return &NameTemplate{text: text}, nil
+}
+
+// String returns the template text
+func (t *NameTemplate) String() string {
+	return t.text
+}
+
+// NameVars are the values a name template is rendered with
+type NameVars struct {
+	RuleID   string
+	Title    string
+	Baseline string
+	Section  string
+	Severity string
+	// References are the rule's references, for {cis_section} and {ref:<name>}
+	References map[string]interface{}
+}
+
+// Render fills in the template. Variables without a value render empty and
+// the surrounding whitespace is collapsed.
+func (t *NameTemplate) Render(vars NameVars) string {
+	name := nameVariablePattern.ReplaceAllStringFunc(t.text, func(match string) string {
+		return vars.lookup(match[1 : len(match)-1])
+	})
+	return strings.TrimSpace(nameSpacePattern.ReplaceAllString(name, " "))
+}
+
+// lookup returns the value of one template variable
+func (vars NameVars) lookup(variable string) string {
+	switch variable {
+	case "rule_id":
+		return vars.RuleID
+	case "title":
+		return vars.Title
+	case "baseline":
+		return vars.Baseline
+	case "section":
+		return vars.Section
+	case "severity":
+		return vars.Severity
+	case "cis_section", "cis_level":
+		m := cisBenchmarkPattern.FindStringSubmatch(firstReference(vars.References, "cis/benchmark"))
+		if m == nil {
+			return ""
+		}
+		if variable == "cis_level" {
+			return m[2]
+		}
+		return strings.TrimSuffix(m[1], ".")
+	}
+	return firstReference(vars.References, strings.TrimPrefix(variable, "ref:"))
+}
+
+// firstReference returns the first value of a reference, addressing nested
+// references with a slash such as cis/benchmark
+func firstReference(references map[string]interface{}, name string) string {
+	var value interface{} = references
+	for _, key := range strings.Split(name, "/") {
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = entries[key]
+	}
+	if list, ok := value.([]interface{}); ok {
+		if len(list) == 0 {
+			return ""
+		}
+		value = list[0]
+	}
+	if value == nil {
+		return ""
+	}
+	if _, ok := value.(map[string]interface{}); ok {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
@@ -0,0 +1,105 @@
+package mscpfleet
+
+import (
+	"regexp"
+)
+
+// Posture modes decide how rules whose check reads the device state are
+// converted when they also have profile keys
+const (
+	// PostureState checks the device state through osquery's native tables
+	PostureState = "state"
+	// PostureProfile checks only the configuration profile keys
+	PostureProfile = "profile"
+	// PostureBoth requires the profile keys and the device state, so a
+	// setting must be both managed and in effect
+	PostureBoth = "both"
+)
+
+// Device state predicates read from osquery's native tables
+var (
+	sipEnabled = queryPredicate{
+		Description: "System Integrity Protection is enabled",
+		Condition:   "EXISTS (" + selectFrom("sip_config", eq("config_flag", "sip"), eq("enabled", 1)) + ")",
+	}
+	gatekeeperEnabled = queryPredicate{
+		Description: "Gatekeeper assessments are enabled",
+		Condition:   "EXISTS (" + selectFrom("gatekeeper", eq("assessments_enabled", 1)) + ")",
+	}
+	firewallEnabled = queryPredicate{
+		Description: "the application firewall is enabled",
+		Condition:   "EXISTS (" + selectFrom("alf", compare("global_state", ">=", 1)) + ")",
+	}
+	firewallStealth = queryPredicate{
+		Description: "firewall stealth mode is enabled",
+		Condition:   "EXISTS (" + selectFrom("alf", eq("stealth_enabled", 1)) + ")",
+	}
+	firewallLogging = queryPredicate{
+		Description: "firewall logging is enabled",
+		Condition:   "EXISTS (" + selectFrom("alf", eq("logging_enabled", 1)) + ")",
+	}
+	firewallNoExceptions = queryPredicate{
+		Description: "the application firewall has no application exceptions",
+		Condition:   "NOT EXISTS (" + selectFrom("alf_exceptions") + ")",
+	}
+	fileVaultOn = queryPredicate{
+		Description: "FileVault is on",
+		Condition:   "EXISTS (" + selectFrom("disk_encryption", eq("filevault_status", "on")) + ")",
+	}
+)
+
+// postureCheck maps a device state command in a check script to its predicate
+type postureCheck struct {
+	pattern   *regexp.Regexp
+	predicate queryPredicate
+}
+
+// postureChecks are the device state commands mSCP checks run
+var postureChecks = []postureCheck{
+	{regexp.MustCompile(`csrutil\s+status`), sipEnabled},
+	{regexp.MustCompile(`spctl\s+(--status|-s)\b`), gatekeeperEnabled},
+	{regexp.MustCompile(`socketfilterfw\s+--getglobalstate|com\.apple\.alf\s+globalstate`), firewallEnabled},
+	{regexp.MustCompile(`socketfilterfw\s+--getstealthmode|com\.apple\.alf\s+stealthenabled`), firewallStealth},
+	{regexp.MustCompile(`socketfilterfw\s+--getloggingmode|com\.apple\.alf\s+loggingenabled`), firewallLogging},
+	{regexp.MustCompile(`socketfilterfw\s+--listapps`), firewallNoExceptions},
+	{regexp.MustCompile(`fdesetup\s+(status|isactive)`), fileVaultOn},
+}
+
+// postureGroups returns the device state predicates for the SIP, Gatekeeper,
+// firewall and FileVault commands a rule's check runs, one group each, and
+// for the sharing service a service-disable rule turns off
+func postureGroups(rule *Rule) []predicateGroup {
+	var groups []predicateGroup
+	for _, check := range postureChecks {
+		if check.pattern.MatchString(rule.Check) {
+			groups = append(groups, predicateGroup{check.predicate})
+		}
+	}
+	if service, ok := sharingServiceFor(rule); ok {
+		groups = append(groups, service.Groups()...)
+	}
+	return groups
+}
+
+// postureQuery returns a policy query that passes when every predicate holds
+func postureQuery(predicates ...queryPredicate) string {
+	groups := make([]predicateGroup, 0, len(predicates))
+	for _, predicate := range predicates {
+		groups = append(groups, predicateGroup{predicate})
+	}
+	return composeQuery(groups)
+}
+
+// combinePosture combines a rule's profile and device state predicates for a posture mode
+func combinePosture(profile, state []predicateGroup, mode string) []predicateGroup {
+	if len(state) == 0 {
+		return profile
+	}
+	switch {
+	case mode == PostureBoth:
+		return append(profile, state...)
+	case mode == PostureProfile && len(profile) > 0:
+		return profile
+	}
+	return state
+}
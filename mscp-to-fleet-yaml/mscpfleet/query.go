@@ -0,0 +1,33 @@
+package mscpfleet
+
+import (
+	"strings"
+)
+
+// Fleet treats a policy as passing when its query returns at least one row,
+// so every generated query must return a row only when the host is compliant.
+// These helpers build the shapes used by the query generator.
+
+// passIfExists returns a policy query that passes when the inner query
+// returns a row, used for required states
+func passIfExists(inner string) string {
+	return "SELECT 1 WHERE EXISTS (" + trimQuery(inner) + ");"
+}
+
+// passIfNotExists returns a policy query that passes when the inner query
+// returns no rows, used for prohibited states
+func passIfNotExists(inner string) string {
+	return "SELECT 1 WHERE NOT EXISTS (" + trimQuery(inner) + ");"
+}
+
+// passIfNone returns a policy query that passes when no row of table
+// matching scope satisfies violation, used for "no file may" rules
+func passIfNone(table, scope, violation string) string {
+	return passIfNotExists(selectFrom(table, scope, violation))
+}
+
+// trimQuery removes surrounding whitespace and the trailing semicolon so a
+// query can be nested in another
+func trimQuery(query string) string {
+	return strings.TrimSuffix(strings.TrimSpace(query), ";")
+}
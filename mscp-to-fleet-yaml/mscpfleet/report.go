@@ -0,0 +1,181 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ExemptedRule records an exemption applied during conversion
+type ExemptedRule struct {
+	RuleID    string
+	Exemption Exemption
+}
+
+// BaselineReport collects the conversion results for a single baseline
+type BaselineReport struct {
+	Name      string
+	Title     string
+	Policies  int
+	Exempted  []ExemptedRule
+	Skipped   []SkippedRule
+	Tailoring *TailoringDelta
+	Info      BaselineInfo
+}
+
+// ComplianceReport collects per-baseline results of a conversion run
+type ComplianceReport struct {
+	Team          string
+	SourceVersion string
+	Baselines     []*BaselineReport
+
+	mu sync.Mutex // guards Baselines during parallel conversion
+}
+
+// NewComplianceReport creates a new compliance report
+func NewComplianceReport(team string) *ComplianceReport {
+	return &ComplianceReport{Team: team}
+}
+
+// AddBaseline adds a baseline to the report and returns its entry
+func (cr *ComplianceReport) AddBaseline(name, title string) *BaselineReport {
+	br := &BaselineReport{Name: name, Title: title}
+	cr.mu.Lock()
+	cr.Baselines = append(cr.Baselines, br)
+	cr.mu.Unlock()
+	return br
+}
+
+// Markdown renders the report as Markdown
+func (cr *ComplianceReport) Markdown() string {
+	var sb strings.Builder
+
+	sb.WriteString("# Compliance Report\n\n")
+	if cr.SourceVersion != "" {
+		fmt.Fprintf(&sb, "mSCP source: %s\n\n", cr.SourceVersion)
+	}
+	if cr.Team != "" {
+		fmt.Fprintf(&sb, "Team: `%s`\n\n", cr.Team)
+	}
+
+	sb.WriteString("| Baseline | Policies | Exempted | Not Automated |\n")
+	sb.WriteString("|----------|----------|----------|---------------|\n")
+	for _, br := range cr.Baselines {
+		fmt.Fprintf(&sb, "| `%s` | %d | %d | %d |\n", br.Name, br.Policies, len(br.Exempted), len(br.Skipped))
+	}
+
+	sb.WriteString("\n## Baselines\n")
+	for _, br := range cr.Baselines {
+		writeBaselineInfo(&sb, br)
+	}
+
+	sb.WriteString("\n## Exemptions\n")
+	exempted := 0
+	for _, br := range cr.Baselines {
+		if len(br.Exempted) == 0 {
+			continue
+		}
+		exempted += len(br.Exempted)
+		fmt.Fprintf(&sb, "\n### %s\n\n", br.Name)
+		sb.WriteString("| Rule | Action | Scope | Justification | Approver | Expires |\n")
+		sb.WriteString("|------|--------|-------|---------------|----------|---------|\n")
+		for _, er := range br.Exempted {
+			e := er.Exemption
+			fmt.Fprintf(&sb, "| `%s` | %s | %s | %s | %s | %s |\n",
+				er.RuleID, e.Action, e.Scope(), markdownCell(e.Justification), markdownCell(e.Approver), e.Expires)
+		}
+	}
+	if exempted == 0 {
+		sb.WriteString("\nNo rules were exempted.\n")
+	}
+
+	sb.WriteString("\n## Rules Not Checked Automatically\n")
+	skipped := 0
+	for _, br := range cr.Baselines {
+		if len(br.Skipped) == 0 {
+			continue
+		}
+		skipped += len(br.Skipped)
+		fmt.Fprintf(&sb, "\n### %s\n\n", br.Name)
+		sb.WriteString("| Rule | Reason |\n")
+		sb.WriteString("|------|--------|\n")
+		for _, sr := range br.Skipped {
+			fmt.Fprintf(&sb, "| `%s` | %s |\n", sr.RuleID, sr.Reason)
+		}
+	}
+	if skipped == 0 {
+		sb.WriteString("\nEvery rule was converted to a policy.\n")
+	}
+
+	for _, br := range cr.Baselines {
+		if br.Tailoring == nil {
+			continue
+		}
+		delta := br.Tailoring
+		fmt.Fprintf(&sb, "\n## Tailored Baseline: %s\n\n", br.Name)
+		fmt.Fprintf(&sb, "Parent: `%s`\n\n", delta.Parent)
+		sb.WriteString("| Change | Rule | Detail |\n")
+		sb.WriteString("|--------|------|--------|\n")
+		for _, ruleID := range delta.Added {
+			fmt.Fprintf(&sb, "| added | `%s` | |\n", ruleID)
+		}
+		for _, ruleID := range delta.Removed {
+			fmt.Fprintf(&sb, "| removed | `%s` | |\n", ruleID)
+		}
+		for _, change := range delta.ODVChanges {
+			fmt.Fprintf(&sb, "| odv | `%s` | %v → %v |\n", change.RuleID, change.Parent, change.Tailored)
+		}
+	}
+
+	return sb.String()
+}
+
+// Write writes the report to the given file
+func (cr *ComplianceReport) Write(filename string) error {
+	return WriteFileAtomic(filename, []byte(cr.Markdown()))
+}
+
+// writeBaselineInfo writes the title and provenance of a baseline
+func writeBaselineInfo(sb *strings.Builder, br *BaselineReport) {
+	info := br.Info
+	var blocks, details []string
+	if br.Title != "" {
+		blocks = append(blocks, br.Title)
+	}
+	if info.Parent != "" {
+		details = append(details, fmt.Sprintf("- Tailored from: `%s`", info.Parent))
+	}
+	if len(info.Merged) > 0 {
+		details = append(details, "- Merged from: `"+strings.Join(info.Merged, "`, `")+"`")
+	}
+	if info.ParentValues != "" {
+		details = append(details, fmt.Sprintf("- Parent values: `%s`", info.ParentValues))
+	}
+	if len(info.Authors) > 0 {
+		names := make([]string, 0, len(info.Authors))
+		for _, author := range info.Authors {
+			names = append(names, author.String())
+		}
+		details = append(details, "- Authors: "+strings.Join(names, ", "))
+	}
+	if len(info.Tags) > 0 {
+		details = append(details, "- Tags: `"+strings.Join(info.Tags, "`, `")+"`")
+	}
+	if len(details) > 0 {
+		blocks = append(blocks, strings.Join(details, "\n"))
+	}
+	if info.Description != "" {
+		blocks = append(blocks, asciiDocToMarkdown(info.Description))
+	}
+
+	fmt.Fprintf(sb, "\n### %s\n", br.Name)
+	if len(blocks) > 0 {
+		fmt.Fprintf(sb, "\n%s\n", strings.Join(blocks, "\n\n"))
+	}
+}
+
+// markdownCell makes text safe for use in a Markdown table cell
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\n", " ")
+	return strings.ReplaceAll(text, "|", "\\|")
+}
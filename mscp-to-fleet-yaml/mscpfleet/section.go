@@ -0,0 +1,74 @@
+package mscpfleet
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sectionTagPrefix starts the tag that records a policy's baseline section
+const sectionTagPrefix = "section_"
+
+var (
+	sectionSlugPattern   = regexp.MustCompile(`[^a-z0-9]+`)
+	sectionPrefixPattern = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+)
+
+// sectionSlug normalizes a baseline section name to snake case, e.g.
+// "Password Policy" to password_policy
+func sectionSlug(section string) string {
+	return strings.Trim(sectionSlugPattern.ReplaceAllString(strings.ToLower(section), "_"), "_")
+}
+
+// sectionTag returns the tag for a baseline section, e.g. section_password_policy,
+// or "" when the section has no name
+func sectionTag(section string) string {
+	slug := sectionSlug(section)
+	if slug == "" {
+		return ""
+	}
+	return sectionTagPrefix + slug
+}
+
+// applySection tags a policy with its baseline section
+func applySection(policy *FleetPolicy, section string) {
+	if tag := sectionTag(section); tag != "" {
+		policy.Spec.Tags = append(policy.Spec.Tags, tag)
+	}
+}
+
+// trimSectionPrefix removes a bracketed section in front of a rule title
+func trimSectionPrefix(title string) string {
+	return sectionPrefixPattern.ReplaceAllString(title, "")
+}
+
+// SplitSections returns one result per section of the baseline, named
+// <baseline>-<section slug> and sorted by name. Policies without a section
+// are grouped under "other".
+func (b *BaselineResult) SplitSections() []*BaselineResult {
+	var parts []*BaselineResult
+	bySection := map[string]*BaselineResult{}
+	for i, policy := range b.Policies {
+		section := ""
+		if i < len(b.Sections) {
+			section = b.Sections[i]
+		}
+		slug := sectionSlug(section)
+		if slug == "" {
+			slug, section = "other", "Other"
+		}
+		part, ok := bySection[slug]
+		if !ok {
+			part = &BaselineResult{Name: b.Name + "-" + slug, Title: b.Title + " - " + section, Info: b.Info}
+			bySection[slug] = part
+			parts = append(parts, part)
+		}
+		part.Policies = append(part.Policies, policy)
+		part.Sections = append(part.Sections, section)
+		if i < len(b.RuleIDs) {
+			part.RuleIDs = append(part.RuleIDs, b.RuleIDs[i])
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Name < parts[j].Name })
+	return parts
+}
@@ -0,0 +1,110 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule severities, from mSCP's severity field. DISA STIG categories map to
+// them: CAT I is high, CAT II medium and CAT III low.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// severityTagPrefix starts the tag that records a policy's severity
+const severityTagPrefix = "severity_"
+
+// normalizeSeverity returns the severity named by a severity or STIG
+// category, e.g. "High", "CAT I" or "cat1" for high, or an error
+func normalizeSeverity(value string) (string, error) {
+	key := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(value)))
+	switch key {
+	case "high", "cati", "cat1":
+		return SeverityHigh, nil
+	case "medium", "catii", "cat2":
+		return SeverityMedium, nil
+	case "low", "catiii", "cat3":
+		return SeverityLow, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (use %s, %s, %s or a STIG category such as CAT I)", value, SeverityHigh, SeverityMedium, SeverityLow)
+}
+
+// SeverityFor returns the rule's severity in a baseline. mSCP writes it as a
+// single value or per baseline, as a map or a list of single-entry maps;
+// baselines without their own value use "default" or "recommended". It
+// returns "" when the rule has no known severity.
+func (r *Rule) SeverityFor(odvKey string) string {
+	var value interface{}
+	switch severity := r.Severity.(type) {
+	case string:
+		value = severity
+	case map[string]interface{}:
+		value = severityEntry(severity, odvKey)
+	case []interface{}:
+		merged := map[string]interface{}{}
+		for _, item := range severity {
+			if entry, ok := item.(map[string]interface{}); ok {
+				for key, v := range entry {
+					merged[key] = v
+				}
+			}
+		}
+		value = severityEntry(merged, odvKey)
+	}
+	text, _ := value.(string)
+	normalized, err := normalizeSeverity(text)
+	if err != nil {
+		return ""
+	}
+	return normalized
+}
+
+// severityEntry picks a baseline's entry from a per-baseline severity map
+func severityEntry(severities map[string]interface{}, odvKey string) interface{} {
+	for _, key := range []string{odvKey, "default", "recommended"} {
+		if value, ok := severities[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// CriticalRules decides which policies are marked critical in Fleet
+type CriticalRules struct {
+	// Severities marks policies of rules with these severities critical
+	Severities []string
+	// RuleIDs marks the policies of these rules critical
+	RuleIDs []string
+}
+
+// NewCriticalRules builds critical rules from severities or STIG categories
+// and rule IDs
+func NewCriticalRules(severities, ruleIDs []string) (CriticalRules, error) {
+	rules := CriticalRules{RuleIDs: ruleIDs}
+	for _, value := range severities {
+		severity, err := normalizeSeverity(value)
+		if err != nil {
+			return CriticalRules{}, err
+		}
+		rules.Severities = append(rules.Severities, severity)
+	}
+	return rules, nil
+}
+
+// IsCritical reports whether a rule with the given severity is critical
+func (cr CriticalRules) IsCritical(ruleID, severity string) bool {
+	return containsString(cr.RuleIDs, ruleID) || (severity != "" && containsString(cr.Severities, severity))
+}
+
+// applySeverity tags a policy with its rule's severity and marks it critical
+// when the critical rules select it
+func applySeverity(policy *FleetPolicy, ruleID, severity string, critical CriticalRules) {
+	if severity != "" {
+		policy.Spec.Tags = append(policy.Spec.Tags, severityTagPrefix+severity)
+	}
+	if critical.IsCritical(ruleID, severity) {
+		policy.Spec.Critical = true
+	}
+}
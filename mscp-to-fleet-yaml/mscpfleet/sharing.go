@@ -0,0 +1,84 @@
+package mscpfleet
+
+import (
+	"regexp"
+)
+
+// sharingService is a service that a rule requires to be off
+type sharingService struct {
+	// Name describes the service in policy descriptions
+	Name string
+	// Column is the sharing_preferences column reporting the service, if any
+	Column string
+	// Labels are the launchd labels of the service's daemons, whose plists
+	// must set Disabled; checked only when there is no Column
+	Labels []string
+}
+
+// sharingServices maps the rule IDs of service-disable rules to their services
+var sharingServices = map[string]sharingService{
+	"system_settings_screen_sharing_disable":    {Name: "Screen Sharing", Column: "screen_sharing"},
+	"system_settings_smbd_disable":              {Name: "File Sharing", Column: "file_sharing"},
+	"system_settings_ssh_disable":               {Name: "Remote Login", Column: "remote_login"},
+	"system_settings_remote_management_disable": {Name: "Remote Management", Column: "remote_management"},
+	"system_settings_rae_disable":               {Name: "Remote Apple Events", Column: "remote_apple_events"},
+	"system_settings_printer_sharing_disable":   {Name: "Printer Sharing", Column: "printer_sharing"},
+	"system_settings_internet_sharing_disable":  {Name: "Internet Sharing", Column: "internet_sharing"},
+	"system_settings_content_caching_disable":   {Name: "Content Caching", Column: "content_caching"},
+	"system_settings_bluetooth_sharing_disable": {Name: "Bluetooth Sharing", Column: "bluetooth_sharing"},
+	"system_settings_media_sharing_disabled":    {Name: "Media Sharing", Labels: []string{"com.apple.amp.mediasharingd"}},
+	"os_httpd_disable":                          {Name: "the Apache web server", Labels: []string{"org.apache.httpd"}},
+	"os_nfsd_disable":                           {Name: "the NFS server", Labels: []string{"com.apple.nfsd"}},
+	"os_tftpd_disable":                          {Name: "the TFTP server", Labels: []string{"com.apple.tftpd"}},
+	"os_uucp_disable":                           {Name: "UUCP", Labels: []string{"com.apple.uucp"}},
+}
+
+var (
+	// launchctlPrintDisabled matches checks that read launchd's disabled services
+	launchctlPrintDisabled = regexp.MustCompile(`launchctl\s+print-disabled`)
+	// launchctlLabelPattern matches the labels a check looks up in that output
+	launchctlLabelPattern = regexp.MustCompile(`"([A-Za-z0-9._-]+)" => (?:enabled|disabled|true|false)`)
+)
+
+// sharingServiceFor returns the service a rule disables, from the rule ID
+// table or the launchd labels its check looks up with launchctl print-disabled
+func sharingServiceFor(rule *Rule) (sharingService, bool) {
+	service, ok := sharingServices[rule.ID]
+	if launchctlPrintDisabled.MatchString(rule.Check) {
+		for _, m := range launchctlLabelPattern.FindAllStringSubmatch(rule.Check, -1) {
+			if !containsString(service.Labels, m[1]) {
+				service.Labels = append(append([]string(nil), service.Labels...), m[1])
+				ok = true
+			}
+		}
+	}
+	return service, ok
+}
+
+// Groups returns the predicates for the service being off. A service with a
+// sharing_preferences column is checked by that column, which reports whether
+// the service is on. Otherwise each launchd job must have the Disabled key
+// set in its plist, which is what osquery's launchd table reports: a job
+// disabled only in launchd's override database, as launchctl disable and
+// launchctl print-disabled use, is not seen.
+func (s sharingService) Groups() []predicateGroup {
+	if s.Column != "" {
+		return []predicateGroup{{{
+			Description: s.Name + " is off in sharing preferences",
+			Condition:   "EXISTS (" + selectFrom("sharing_preferences", eq(s.Column, 0)) + ")",
+		}}}
+	}
+	var groups []predicateGroup
+	for _, label := range s.Labels {
+		groups = append(groups, predicateGroup{{
+			Description: "the launchd plist of " + label + " sets Disabled",
+			Condition:   "NOT EXISTS (" + selectFrom("launchd", eq("label", label), notEq("disabled", "1")) + ")",
+		}})
+	}
+	return groups
+}
+
+// sharingQuery returns a policy query that passes when the service a rule ID disables is off
+func sharingQuery(ruleID string) string {
+	return composeQuery(sharingServices[ruleID].Groups())
+}
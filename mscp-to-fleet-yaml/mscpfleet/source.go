@@ -0,0 +1,256 @@
+package mscpfleet
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a macOS Security Compliance Project tree read through a virtual filesystem
+type Source struct {
+	FS       fs.FS
+	Location string
+	Version  string
+	// Dir is the local directory the source was read from, empty for archives and git refs
+	Dir string
+}
+
+// mscpVersion mirrors the VERSION.yaml file at the root of the mSCP repository
+type mscpVersion struct {
+	OS      string `yaml:"os"`
+	Version string `yaml:"version"`
+}
+
+// OpenSource opens an mSCP source: a directory, a .tar.gz/.tgz or .zip
+// release archive, or a local git repository read at ref
+func OpenSource(location, ref string) (*Source, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, fmt.Errorf("mSCP source not found: %w", err)
+	}
+
+	var source *Source
+	switch {
+	case info.IsDir() && ref != "":
+		source, err = openGitSource(location, ref)
+	case info.IsDir():
+		source, err = openDirSource(location)
+	case strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz"):
+		source, err = openTarGzSource(location)
+	case strings.HasSuffix(location, ".zip"):
+		source, err = openZipSource(location)
+	default:
+		return nil, fmt.Errorf("unsupported mSCP source %s: expected a directory, .tar.gz, .tgz or .zip", location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := source.check(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// NewSource wraps an mSCP tree already available as a filesystem, such as an
+// embed.FS or fstest.MapFS. Location and version describe the tree in output
+// headers; the guidance version from VERSION.yaml is appended when present.
+func NewSource(fsys fs.FS, location, version string) (*Source, error) {
+	source := &Source{FS: fsys, Location: location, Version: version}
+	if err := source.check(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// check verifies the tree has baselines and appends the guidance version recorded by mSCP itself
+func (s *Source) check() error {
+	if _, err := fs.Stat(s.FS, "baselines"); err != nil {
+		return fmt.Errorf("mSCP source %s has no baselines directory", s.Location)
+	}
+
+	var version mscpVersion
+	if err := loadYAMLFS(s.FS, "VERSION.yaml", &version); err == nil && version.Version != "" {
+		if s.Version == "" {
+			s.Version = version.Version
+		} else {
+			s.Version = fmt.Sprintf("%s, %s", s.Version, version.Version)
+		}
+	}
+	return nil
+}
+
+// openDirSource opens a directory, describing its git state when it is a checkout
+func openDirSource(dir string) (*Source, error) {
+	version := "unversioned directory"
+	if !isGitRoot(dir) {
+		return &Source{FS: os.DirFS(dir), Location: dir, Version: version, Dir: dir}, nil
+	}
+	if describe, err := gitOutput(dir, "describe", "--tags", "--always", "--dirty"); err == nil {
+		version = describe
+		if commit, err := gitOutput(dir, "rev-parse", "HEAD"); err == nil && !strings.HasPrefix(commit, describe) {
+			version = fmt.Sprintf("%s (%s)", describe, commit)
+		}
+	}
+	return &Source{FS: os.DirFS(dir), Location: dir, Version: version, Dir: dir}, nil
+}
+
+// openGitSource reads the tree of a git repository at ref without touching its working copy
+func openGitSource(repo, ref string) (*Source, error) {
+	commit, err := gitOutput(repo, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve git ref %s in %s: %w", ref, repo, err)
+	}
+
+	cmd := exec.Command("git", "-C", repo, "archive", "--format=tar", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %v: %s", repo, ref, err, strings.TrimSpace(stderr.String()))
+	}
+
+	fsys, err := readTar(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", repo, ref, err)
+	}
+	return &Source{FS: fsys, Location: repo, Version: fmt.Sprintf("%s (%s)", ref, commit)}, nil
+}
+
+// openTarGzSource reads a gzipped tar release archive into memory
+func openTarGzSource(archive string) (*Source, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+	}
+	defer gz.Close()
+
+	fsys, err := readTar(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+	}
+	return &Source{FS: stripArchiveRoot(fsys), Location: archive, Version: filepath.Base(archive)}, nil
+}
+
+// openZipSource reads a zip release archive into memory
+func openZipSource(archive string) (*Source, error) {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+	}
+	defer reader.Close()
+
+	fsys := memFS{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || !isSourceFile(file.Name) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, archive, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in %s: %w", file.Name, archive, err)
+		}
+		fsys[path.Clean(file.Name)] = data
+	}
+	return &Source{FS: stripArchiveRoot(fsys), Location: archive, Version: filepath.Base(archive)}, nil
+}
+
+// readTar loads the YAML files of a tar stream into an in-memory filesystem
+func readTar(r io.Reader) (memFS, error) {
+	fsys := memFS{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || !isSourceFile(header.Name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		fsys[path.Clean(header.Name)] = data
+	}
+	return fsys, nil
+}
+
+// isSourceFile reports whether an archive entry is needed for conversion
+func isSourceFile(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// stripArchiveRoot removes the single top-level directory release archives
+// wrap their contents in, such as macos_security-2.0/
+func stripArchiveRoot(fsys memFS) fs.FS {
+	root := ""
+	for name := range fsys {
+		top, _, found := strings.Cut(name, "/")
+		if !found || (root != "" && top != root) {
+			return fsys
+		}
+		root = top
+	}
+	if root == "" {
+		return fsys
+	}
+	sub, err := fs.Sub(fsys, root)
+	if err != nil {
+		return fsys
+	}
+	return sub
+}
+
+// isGitRoot reports whether dir is the top level of a git working copy
+func isGitRoot(dir string) bool {
+	top, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	return filepath.Clean(top) == abs
+}
+
+// gitOutput runs a git command in dir and returns its trimmed output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,392 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The query generator builds SQL from values scraped from rule checks and
+// profile keys, so every value goes through these helpers instead of being
+// formatted into a query directly. Generated queries are normalized with
+// FormatSQL, which also checks that they parse.
+
+// likeEscape is the escape character used in LIKE patterns
+const likeEscape = `\`
+
+// literal quotes a value as an SQL literal: strings are single-quoted with
+// embedded quotes doubled, booleans become 1 or 0 and numbers are unquoted
+func literal(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return literal(fmt.Sprint(value))
+}
+
+// eq returns "column = value" with value quoted as a literal
+func eq(column string, value interface{}) string {
+	return column + " = " + literal(value)
+}
+
+// notEq returns "column != value" with value quoted as a literal
+func notEq(column string, value interface{}) string {
+	return column + " != " + literal(value)
+}
+
+// compare returns "column op value" with value quoted as a literal
+func compare(column, op string, value interface{}) string {
+	return column + " " + op + " " + literal(value)
+}
+
+// escapeLike escapes the LIKE wildcards % and _ and the escape character in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(s)
+}
+
+// like returns a LIKE condition for a pattern built from escaped text, adding
+// an ESCAPE clause only when the pattern needs one
+func like(column, pattern string) string {
+	condition := column + " LIKE " + literal(pattern)
+	if strings.Contains(pattern, likeEscape) {
+		condition += " ESCAPE " + literal(likeEscape)
+	}
+	return condition
+}
+
+// hasPrefix returns a condition matching column values that start with prefix
+func hasPrefix(column, prefix string) string {
+	return like(column, escapeLike(prefix)+"%")
+}
+
+// contains returns a condition matching column values that contain substr
+func contains(column, substr string) string {
+	return like(column, "%"+escapeLike(substr)+"%")
+}
+
+// and joins conditions with AND, parenthesizing conditions that contain a
+// top-level OR when there is more than one
+func and(conditions ...string) string {
+	parts := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	if len(parts) > 1 {
+		for i, condition := range parts {
+			if hasTopLevelOr(condition) {
+				parts[i] = "(" + condition + ")"
+			}
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// or joins conditions with OR
+func or(conditions ...string) string {
+	parts := make([]string, 0, len(conditions))
+	for _, condition := range conditions {
+		if condition != "" {
+			parts = append(parts, condition)
+		}
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// selectFrom returns "SELECT 1 FROM table WHERE conditions", the inner query of
+// the policy shapes in query.go
+func selectFrom(table string, conditions ...string) string {
+	where := and(conditions...)
+	if where == "" {
+		return "SELECT 1 FROM " + table
+	}
+	return "SELECT 1 FROM " + table + " WHERE " + where
+}
+
+// hasTopLevelOr reports whether a condition has an OR outside parentheses and literals
+func hasTopLevelOr(condition string) bool {
+	tokens, err := tokenizeSQL(condition)
+	if err != nil {
+		return strings.Contains(strings.ToUpper(condition), " OR ")
+	}
+	depth := 0
+	for _, tok := range tokens {
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth == 0 && tok.kind == sqlWord && strings.EqualFold(tok.text, "OR"):
+			return true
+		}
+	}
+	return false
+}
+
+// sqlTokenKind classifies SQL tokens
+type sqlTokenKind int
+
+const (
+	sqlWord sqlTokenKind = iota
+	sqlNumber
+	sqlString
+	sqlQuoted
+	sqlSymbol
+)
+
+// sqlToken is one lexical token of a query
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// sqlKeywords are uppercased by FormatSQL; a keyword followed by "(" keeps a space
+var sqlKeywords = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true, "CASE": true,
+	"DESC": true, "DISTINCT": true, "ELSE": true, "END": true, "ESCAPE": true, "EXISTS": true,
+	"FROM": true, "GLOB": true, "GROUP": true, "HAVING": true, "IN": true, "IS": true, "JOIN": true,
+	"LEFT": true, "LIKE": true, "LIMIT": true, "NOT": true, "NULL": true, "ON": true, "OR": true,
+	"ORDER": true, "REGEXP": true, "SELECT": true, "THEN": true, "UNION": true, "WHEN": true,
+	"WHERE": true, "WITH": true,
+}
+
+// sqlOperators are multi-character operators, longest first
+var sqlOperators = []string{"<=", ">=", "!=", "<>", "==", "||", "<<", ">>"}
+
+// tokenizeSQL splits a query into tokens, failing on unterminated literals
+// and characters SQLite does not accept
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing, kind := c, sqlQuoted
+			switch c {
+			case '\'':
+				kind = sqlString
+			case '[':
+				closing = ']'
+			}
+			j := i + 1
+			for {
+				if j >= len(query) {
+					if kind == sqlString {
+						return nil, fmt.Errorf("unterminated string literal at offset %d", i)
+					}
+					return nil, fmt.Errorf("unterminated quoted identifier at offset %d", i)
+				}
+				if query[j] == closing {
+					if closing != ']' && j+1 < len(query) && query[j+1] == closing {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind, query[i : j+1]})
+			i = j + 1
+		case isWordByte(c) && !(c >= '0' && c <= '9'):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{sqlWord, query[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := numberEnd(query, i)
+			tokens = append(tokens, sqlToken{sqlNumber, query[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range sqlOperators {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("=<>+-*/%&|~(),;.?:@", rune(c)) {
+					return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+				}
+				op = string(c)
+			}
+			tokens = append(tokens, sqlToken{sqlSymbol, op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// numberEnd returns the end of the numeric literal starting at i: a
+// hexadecimal integer, or digits with an optional fraction and an exponent
+// such as 1e-05 or 1.5E+10. Letters run on to the number so SQLite reports
+// them rather than the formatter splitting them off.
+func numberEnd(query string, i int) int {
+	j := i
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0X") {
+		j += 2
+	} else {
+		for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+			j++
+		}
+		if j < len(query) && (query[j] == 'e' || query[j] == 'E') {
+			k := j + 1
+			if k < len(query) && (query[k] == '+' || query[k] == '-') {
+				k++
+			}
+			if k < len(query) && isDigit(query[k]) {
+				j = k
+			}
+		}
+	}
+	for j < len(query) && (isWordByte(query[j]) || query[j] == '.') {
+		j++
+	}
+	return j
+}
+
+// isDigit reports whether c is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isWordByte reports whether c can be part of an identifier, keyword or number
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// ParseSQL checks that a policy query is a single SELECT statement with
+// terminated literals and balanced parentheses
+func ParseSQL(query string) error {
+	_, err := parseSQL(query)
+	return err
+}
+
+// parseSQL tokenizes and checks a query
+func parseSQL(query string) ([]sqlToken, error) {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+	if first := strings.ToUpper(tokens[0].text); first != "SELECT" && first != "WITH" {
+		return nil, fmt.Errorf("query starts with %s, not SELECT", tokens[0].text)
+	}
+	depth := 0
+	for i, tok := range tokens {
+		if tok.kind != sqlSymbol {
+			continue
+		}
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses: unexpected )")
+			}
+		case ";":
+			if i != len(tokens)-1 {
+				return nil, fmt.Errorf("query has more than one statement")
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses: %d not closed", depth)
+	}
+	return tokens, nil
+}
+
+// FormatSQL returns a query in the normalized form the generator emits:
+// keywords uppercased, single spaces between tokens, none inside parentheses
+// or before commas, and a trailing semicolon. Queries that do not parse are
+// returned unchanged with the parse error.
+func FormatSQL(query string) (string, error) {
+	tokens, err := parseSQL(query)
+	if err != nil {
+		return query, err
+	}
+	if last := tokens[len(tokens)-1]; last.kind != sqlSymbol || last.text != ";" {
+		tokens = append(tokens, sqlToken{sqlSymbol, ";"})
+	}
+
+	for i, tok := range tokens {
+		if tok.kind == sqlWord && sqlKeywords[strings.ToUpper(tok.text)] {
+			tokens[i].text = strings.ToUpper(tok.text)
+		}
+	}
+
+	var sb strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], tok, i, tokens) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok.text)
+	}
+	return sb.String(), nil
+}
+
+// normalizeQuery formats a query with FormatSQL so formatting differences are
+// ignored, collapsing whitespace in queries that do not parse
+func normalizeQuery(query string) string {
+	if formatted, err := FormatSQL(query); err == nil {
+		return formatted
+	}
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// needsSpace decides whether a space separates two adjacent tokens
+func needsSpace(prev, tok sqlToken, i int, tokens []sqlToken) bool {
+	switch {
+	case tok.text == ")" || tok.text == "," || tok.text == ";" || tok.text == ".":
+		return false
+	case prev.text == "(" || prev.text == ".":
+		return false
+	case tok.text == "(" && prev.kind == sqlWord && !sqlKeywords[prev.text]:
+		// Function call such as COUNT(*) or CAST(value AS INTEGER)
+		return false
+	case prev.kind == sqlSymbol && prev.text == "-" && tok.kind == sqlNumber && i >= 2 && isOperandStart(tokens[i-2]):
+		// Negative number such as value = -1
+		return false
+	}
+	return true
+}
+
+// isOperandStart reports whether a token is followed by an operand, so a
+// minus sign after it is unary
+func isOperandStart(tok sqlToken) bool {
+	if tok.kind == sqlSymbol {
+		return tok.text != ")"
+	}
+	return tok.kind == sqlWord && sqlKeywords[tok.text]
+}
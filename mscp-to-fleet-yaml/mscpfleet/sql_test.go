@@ -0,0 +1,211 @@
+package mscpfleet
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// runSQLite runs statements in an in-memory database with the sqlite3
+// command and returns its output, skipping the test when sqlite3 is missing
+func runSQLite(t *testing.T, statements string) string {
+	t.Helper()
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(sqlite3, "-bail", ":memory:")
+	cmd.Stdin = strings.NewReader(statements)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil || stderr.Len() > 0 {
+		t.Fatalf("sqlite3 failed: %v\n%s\nstatements:\n%s", err, stderr.String(), statements)
+	}
+	return stdout.String()
+}
+
+func TestFormatSQL(t *testing.T) {
+	for _, tt := range []struct {
+		query, want string
+	}{
+		{"select 'it''s'  ||  '50%'", "SELECT 'it''s' || '50%';"},
+		{"SELECT 1 WHERE 1e-05 = 0.00001", "SELECT 1 WHERE 1e-05 = 0.00001;"},
+		{"SELECT 1.5E+10 ,-1, 2-1,0x1F, 2.5e3", "SELECT 1.5E+10, -1, 2 - 1, 0x1F, 2.5e3;"},
+		{"SELECT abs( -3 ) * 2", "SELECT abs(-3) * 2;"},
+		{`select 1 where 'a_b' like 'a\_%' escape '\'`, `SELECT 1 WHERE 'a_b' LIKE 'a\_%' ESCAPE '\';`},
+		{`SELECT 1 WHERE 'a\b' LIKE '%\\%' ESCAPE '\'`, `SELECT 1 WHERE 'a\b' LIKE '%\\%' ESCAPE '\';`},
+		{"SELECT 1 WHERE NOT EXISTS ( SELECT 1 WHERE 0 )", "SELECT 1 WHERE NOT EXISTS (SELECT 1 WHERE 0);"},
+		{"SELECT 1 WHERE -1 < 0 -- trailing comment", "SELECT 1 WHERE -1 < 0;"},
+	} {
+		got, err := FormatSQL(tt.query)
+		if err != nil {
+			t.Errorf("FormatSQL(%q): %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("FormatSQL(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+		if again, _ := FormatSQL(got); again != got {
+			t.Errorf("FormatSQL(%q) = %q, not stable", got, again)
+		}
+		if raw, formatted := runSQLite(t, tt.query+";\n"), runSQLite(t, got+"\n"); raw != formatted {
+			t.Errorf("%q returns %q in SQLite, formatted %q returns %q", tt.query, raw, got, formatted)
+		}
+	}
+}
+
+func TestParseSQLErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"DELETE FROM t",
+		"SELECT 'x",
+		"SELECT [x",
+		"SELECT 1; SELECT 2",
+		"SELECT (1",
+		"SELECT 1)",
+		"SELECT 1 /* comment",
+		"SELECT 1 WHERE x = {}",
+	} {
+		if err := ParseSQL(query); err == nil {
+			t.Errorf("ParseSQL(%q) succeeded", query)
+		}
+	}
+}
+
+// sqliteValues are the rows the helper conditions are evaluated against
+var sqliteValues = []string{
+	"it's", "50%_off", "50%", "50x_off", "500_off", `a\b`, "ab", "a_b", "axb",
+	"-1", "1e-05", "0.00001", "15000000000",
+}
+
+func TestSQLHelpersInSQLite(t *testing.T) {
+	var setup strings.Builder
+	setup.WriteString("CREATE TABLE t (v TEXT);\n")
+	for _, value := range sqliteValues {
+		setup.WriteString("INSERT INTO t VALUES (" + literal(value) + ");\n")
+	}
+
+	for _, tt := range []struct {
+		condition string
+		want      []string
+	}{
+		{eq("v", "it's"), []string{"it's"}},
+		{contains("v", "'"), []string{"it's"}},
+		{hasPrefix("v", "50%_"), []string{"50%_off"}},
+		{and(hasPrefix("v", "50%"), notEq("v", "50%")), []string{"50%_off"}},
+		{contains("v", "_"), []string{"50%_off", "50x_off", "500_off", "a_b"}},
+		{hasPrefix("v", "a_"), []string{"a_b"}},
+		{contains("v", `\`), []string{`a\b`}},
+		{and(or(eq("v", "ab"), eq("v", "axb")), notEq("v", "ab")), []string{"axb"}},
+		{eq("CAST(v AS INTEGER)", -1), []string{"-1"}},
+		{compare("CAST(v AS REAL)", "<", -0.5), []string{"-1"}},
+		{eq("CAST(v AS REAL)", 0.00001), []string{"1e-05", "0.00001"}},
+		{eq("CAST(v AS REAL)", 1.5e10), []string{"15000000000"}},
+	} {
+		query := "SELECT v FROM t WHERE " + tt.condition + " ORDER BY rowid"
+		formatted, err := FormatSQL(query)
+		if err != nil {
+			t.Errorf("FormatSQL(%q): %v", query, err)
+			continue
+		}
+		want := ""
+		if len(tt.want) > 0 {
+			want = strings.Join(tt.want, "\n") + "\n"
+		}
+		if got := runSQLite(t, setup.String()+query+";\n"); got != want {
+			t.Errorf("%s matches %q, want %q", query, got, want)
+		}
+		if got := runSQLite(t, setup.String()+formatted+"\n"); got != want {
+			t.Errorf("%s matches %q, want %q", formatted, got, want)
+		}
+	}
+}
+
+func TestManagedPolicyQueryInSQLite(t *testing.T) {
+	for _, tt := range []struct {
+		value  interface{}
+		stored string
+		pass   bool
+	}{
+		{true, "1", true},
+		{true, "0", false},
+		{false, "false", true},
+		{900, "900", true},
+		{900, "1200", false},
+		{-1, "-1", true},
+		{0.00001, "1e-05", true},
+		{0.00001, "0.0001", false},
+		{1.5e10, "15000000000", true},
+		{"it's", "it's", true},
+		{"50%", "500", false},
+	} {
+		query := normalizeQuery(managedPolicyQuery("com.example", "key", tt.value))
+		if isUnmappedQuery(query) {
+			t.Errorf("%v has no query", tt.value)
+			continue
+		}
+		statements := "CREATE TABLE managed_policies (domain TEXT, name TEXT, value TEXT);\n" +
+			"INSERT INTO managed_policies VALUES ('com.example', 'key', " + literal(tt.stored) + ");\n" +
+			query + "\n"
+		if got := runSQLite(t, statements) != ""; got != tt.pass {
+			t.Errorf("%s with value %q: passes %v, want %v", query, tt.stored, got, tt.pass)
+		}
+	}
+}
+
+func TestQueryMappingsParse(t *testing.T) {
+	for _, mapping := range queryMappings() {
+		if mapping.Query == "" {
+			continue
+		}
+		if err := ParseSQL(mapping.Query); err != nil {
+			t.Errorf("query for %s does not parse: %v\n%s", mapping.Pattern, err, mapping.Query)
+		}
+		if _, err := FormatSQL(mapping.Query); err != nil {
+			t.Errorf("query for %s does not format: %v", mapping.Pattern, err)
+		}
+	}
+}
+
+func TestAuditFileModeQueryInSQLite(t *testing.T) {
+	query := normalizeQuery(auditDirectoryCheck(false, fileMode, 0440).Query())
+	for _, tt := range []struct {
+		modes []string
+		pass  bool
+	}{
+		{[]string{"0440", "0440"}, true},
+		{[]string{"0440", "0400"}, false},
+		{[]string{"0440", "4440"}, false},
+		{[]string{"0640"}, false},
+	} {
+		statements := "CREATE TABLE file (path TEXT, directory TEXT, filename TEXT, type TEXT, mode TEXT, uid INTEGER, gid INTEGER);\n" +
+			"INSERT INTO file VALUES ('/var/audit/current', '/var/audit', 'current', 'symlink', '0755', 0, 0);\n"
+		for i, mode := range tt.modes {
+			name := fmt.Sprintf("log%d", i)
+			statements += "INSERT INTO file VALUES (" + literal("/var/audit/"+name) + ", '/var/audit', " + literal(name) + ", 'regular', " + literal(mode) + ", 0, 0);\n"
+		}
+		if got := runSQLite(t, statements+query+"\n") != ""; got != tt.pass {
+			t.Errorf("%s with modes %v: passes %v, want %v", query, tt.modes, got, tt.pass)
+		}
+	}
+}
+
+func TestLsPermBits(t *testing.T) {
+	for perm, want := range map[string]int{
+		"-r--r-----": 0440,
+		"-rwsr-xr-x": 04755,
+		"-rwSr--r--": 04644,
+		"-rwxr-s---": 02750,
+		"drwxrwxrwt": 01777,
+		"drwxr-xr-T": 01754,
+	} {
+		if !lsPermPattern.MatchString(perm) {
+			t.Errorf("%s is not recognized", perm)
+		}
+		if got := lsPermBits(perm); got != want {
+			t.Errorf("lsPermBits(%s) = %#o, want %#o", perm, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package mscpfleet
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Tailoring describes a custom baseline composed from an existing one
+type Tailoring struct {
+	Name        string                 `yaml:"name"`
+	Title       string                 `yaml:"title"`
+	Description string                 `yaml:"description"`
+	Parent      string                 `yaml:"parent"`
+	Tags        []string               `yaml:"tags"`
+	Add         []Section              `yaml:"add"`
+	Remove      []string               `yaml:"remove"`
+	ODV         map[string]interface{} `yaml:"odv"`
+}
+
+// ODVChange records an organization defined value changed by tailoring
+type ODVChange struct {
+	RuleID   string
+	Parent   interface{}
+	Tailored interface{}
+}
+
+// TailoringDelta describes how a tailored baseline differs from its parent
+type TailoringDelta struct {
+	Parent     string
+	Added      []string
+	Removed    []string
+	ODVChanges []ODVChange
+}
+
+// LoadTailoring loads and validates a tailoring file
+func LoadTailoring(filename string) (*Tailoring, error) {
+	var tailoring Tailoring
+	if err := loadYAML(filename, &tailoring); err != nil {
+		return nil, fmt.Errorf("failed to load tailoring %s: %w", filename, err)
+	}
+	if tailoring.Parent == "" {
+		return nil, fmt.Errorf("tailoring %s: parent is required", filename)
+	}
+	if tailoring.Name == "" {
+		return nil, fmt.Errorf("tailoring %s: name is required", filename)
+	}
+	if tailoring.Name == tailoring.Parent {
+		return nil, fmt.Errorf("tailoring %s: name must differ from parent %s", filename, tailoring.Parent)
+	}
+	for _, section := range tailoring.Add {
+		if section.Section == "" {
+			return nil, fmt.Errorf("tailoring %s: every added rule group needs a section", filename)
+		}
+	}
+	return &tailoring, nil
+}
+
+// TailorBaseline builds an in-memory baseline from a tailoring and its parent
+func (bc *BaselineConverter) TailorBaseline(tailoring *Tailoring) (*Baseline, *TailoringDelta, error) {
+	parent, err := bc.LoadBaseline(tailoring.Parent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delta := &TailoringDelta{Parent: tailoring.Parent}
+
+	removed := map[string]bool{}
+	for _, ruleID := range tailoring.Remove {
+		removed[ruleID] = true
+	}
+
+	// Copy the parent profile without the removed rules
+	inParent := map[string]bool{}
+	profile := []Section{}
+	for _, section := range parent.Profile {
+		rules := []string{}
+		for _, ruleID := range section.Rules {
+			inParent[ruleID] = true
+			if removed[ruleID] {
+				delta.Removed = append(delta.Removed, ruleID)
+				continue
+			}
+			rules = append(rules, ruleID)
+		}
+		profile = append(profile, Section{Section: section.Section, Rules: rules})
+	}
+	for _, ruleID := range tailoring.Remove {
+		if !inParent[ruleID] {
+			bc.diagnostics.Warn(Warning{Message: "tailoring removes a rule that is not in its parent " + tailoring.Parent, RuleID: ruleID, Baseline: tailoring.Name})
+		}
+	}
+
+	// Merge added rules into matching sections, creating new ones as needed
+	for _, added := range tailoring.Add {
+		idx := -1
+		for i := range profile {
+			if strings.EqualFold(profile[i].Section, added.Section) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			profile = append(profile, Section{Section: added.Section})
+			idx = len(profile) - 1
+		}
+		for _, ruleID := range added.Rules {
+			if inParent[ruleID] && !removed[ruleID] {
+				bc.diagnostics.Warn(Warning{Message: "tailoring adds a rule that is already in its parent " + tailoring.Parent, RuleID: ruleID, Baseline: tailoring.Name})
+				continue
+			}
+			profile[idx].Rules = append(profile[idx].Rules, ruleID)
+			delta.Added = append(delta.Added, ruleID)
+		}
+	}
+
+	title := tailoring.Title
+	if title == "" {
+		title = fmt.Sprintf("%s (tailored from %s)", tailoring.Name, parent.Title)
+	}
+	description := tailoring.Description
+	if description == "" {
+		description = parent.Description
+	}
+	tags := append([]string{}, parent.Tags...)
+	for _, tag := range tailoring.Tags {
+		if !containsString(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	parentValues := parent.ODVKey(tailoring.Parent)
+	baseline := &Baseline{
+		Title:        title,
+		Description:  description,
+		Authors:      parent.Authors,
+		ParentValues: parentValues,
+		Tags:         tags,
+		Profile:      profile,
+		Parent:       tailoring.Parent,
+		ODVOverrides: tailoring.ODV,
+	}
+
+	// Record ODV changes against the values the parent would use
+	included := map[string]bool{}
+	for _, ruleID := range baseline.RuleIDs() {
+		included[ruleID] = true
+	}
+	odvRules := make([]string, 0, len(tailoring.ODV))
+	for ruleID := range tailoring.ODV {
+		odvRules = append(odvRules, ruleID)
+	}
+	sort.Strings(odvRules)
+	for _, ruleID := range odvRules {
+		if !included[ruleID] {
+			return nil, nil, fmt.Errorf("tailoring %s sets an ODV for rule %s which is not in the baseline", tailoring.Name, ruleID)
+		}
+		rule, err := bc.LoadRule(ruleID)
+		if errors.Is(err, ErrRuleNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if rule.ODV == nil {
+			return nil, nil, fmt.Errorf("tailoring %s sets an ODV for rule %s which has no organization defined value", tailoring.Name, ruleID)
+		}
+		parentValue := rule.DefaultODV(parentValues)
+		if !reflect.DeepEqual(parentValue, tailoring.ODV[ruleID]) {
+			delta.ODVChanges = append(delta.ODVChanges, ODVChange{RuleID: ruleID, Parent: parentValue, Tailored: tailoring.ODV[ruleID]})
+		}
+	}
+
+	return baseline, delta, nil
+}
+
+// ConvertTailoring builds a tailored baseline and converts it to Fleet policies
+func (bc *BaselineConverter) ConvertTailoring(tailoring *Tailoring) (*BaselineResult, error) {
+	baseline, delta, err := bc.TailorBaseline(tailoring)
+	if err != nil {
+		return nil, err
+	}
+
+	bc.diagnostics.Logger().Info("tailored baseline", "baseline", tailoring.Name, "parent", delta.Parent,
+		"added", len(delta.Added), "removed", len(delta.Removed), "odv_changes", len(delta.ODVChanges))
+
+	baselineReport := bc.report.AddBaseline(tailoring.Name, baseline.Title)
+	baselineReport.Tailoring = delta
+	return bc.ConvertBaseline(baseline, tailoring.Name, baselineReport)
+}
@@ -0,0 +1,433 @@
+package mscpfleet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FleetPolicy represents a Fleet policy structure
+type FleetPolicy struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Spec       PolicySpec `yaml:"spec"`
+}
+
+// PolicySpec represents the policy specification
+type PolicySpec struct {
+	Name         string   `yaml:"name"`
+	Platforms    string   `yaml:"platforms"`
+	Platform     string   `yaml:"platform"`
+	Description  string   `yaml:"description"`
+	Resolution   string   `yaml:"resolution"`
+	Query        string   `yaml:"query"`
+	Purpose      string   `yaml:"purpose"`
+	Tags         []string `yaml:"tags"`
+	Contributors string   `yaml:"contributors"`
+	Critical     bool     `yaml:"critical"`
+
+	LabelsIncludeAny []string `yaml:"labels_include_any,omitempty"`
+}
+
+// FleetLabel represents a Fleet label structure
+type FleetLabel struct {
+	APIVersion string    `yaml:"apiVersion"`
+	Kind       string    `yaml:"kind"`
+	Spec       LabelSpec `yaml:"spec"`
+}
+
+// LabelSpec represents the label specification
+type LabelSpec struct {
+	Name                string `yaml:"name"`
+	Description         string `yaml:"description"`
+	Query               string `yaml:"query"`
+	Platform            string `yaml:"platform"`
+	LabelMembershipType string `yaml:"label_membership_type"`
+}
+
+// Baseline represents a baseline configuration
+type Baseline struct {
+	Title        string    `yaml:"title"`
+	Description  string    `yaml:"description"`
+	Authors      string    `yaml:"authors"`
+	ParentValues string    `yaml:"parent_values"`
+	Tags         []string  `yaml:"tags"`
+	Profile      []Section `yaml:"profile"`
+
+	// Parent and ODVOverrides are set for tailored baselines built in memory
+	Parent       string                 `yaml:"-"`
+	ODVOverrides map[string]interface{} `yaml:"-"`
+}
+
+// ODVKey returns the key a baseline's organization defined values are looked
+// up under in rules: its parent_values, then its tailoring parent, then its name
+func (b *Baseline) ODVKey(baselineName string) string {
+	switch {
+	case b.ParentValues != "":
+		return b.ParentValues
+	case b.Parent != "":
+		return b.Parent
+	}
+	return baselineName
+}
+
+// ODVValue returns the organization defined value a rule takes in this
+// baseline, or nil if the rule has no ODV
+func (b *Baseline) ODVValue(rule *Rule, baselineName string) interface{} {
+	if value, ok := b.ODVOverrides[rule.ID]; ok {
+		return value
+	}
+	return rule.DefaultODV(b.ODVKey(baselineName))
+}
+
+// RuleIDs returns every rule ID in the baseline in profile order
+func (b *Baseline) RuleIDs() []string {
+	var ids []string
+	for _, section := range b.Profile {
+		ids = append(ids, section.Rules...)
+	}
+	return ids
+}
+
+// Section represents a section in a baseline
+type Section struct {
+	Section string   `yaml:"section"`
+	Rules   []string `yaml:"rules"`
+}
+
+// Rule represents a security rule definition
+type Rule struct {
+	ID         string                 `yaml:"id"`
+	Title      string                 `yaml:"title"`
+	Discussion string                 `yaml:"discussion"`
+	Fix        string                 `yaml:"fix"`
+	Check      string                 `yaml:"check"`
+	References map[string]interface{} `yaml:"references"`
+	ODV        map[string]interface{} `yaml:"odv"`
+	MacOS      []string               `yaml:"macOS"`
+	Platforms  map[string]interface{} `yaml:"platforms"`
+	Tags       []string               `yaml:"tags"`
+	Result     map[string]interface{} `yaml:"result"`
+	// Severity is a single value or per-baseline values (see SeverityFor)
+	Severity interface{} `yaml:"severity"`
+
+	MobileconfigInfo map[string]interface{} `yaml:"mobileconfig_info"`
+}
+
+// DefaultODV returns the rule's organization defined value for a baseline,
+// falling back to the recommended value
+func (r *Rule) DefaultODV(baselineName string) interface{} {
+	if r.ODV == nil {
+		return nil
+	}
+	if value, ok := r.ODV[baselineName]; ok {
+		return value
+	}
+	return r.ODV["recommended"]
+}
+
+// WithODV returns a copy of the rule with $ODV placeholders replaced by value
+func (r *Rule) WithODV(value interface{}) *Rule {
+	if value == nil {
+		return r
+	}
+	odv := fmt.Sprint(value)
+	resolved := *r
+	resolved.Title = strings.ReplaceAll(r.Title, "$ODV", odv)
+	resolved.Discussion = strings.ReplaceAll(r.Discussion, "$ODV", odv)
+	resolved.Check = strings.ReplaceAll(r.Check, "$ODV", odv)
+	resolved.Fix = strings.ReplaceAll(r.Fix, "$ODV", odv)
+	if r.MobileconfigInfo != nil {
+		resolved.MobileconfigInfo = replaceODV(r.MobileconfigInfo, value).(map[string]interface{})
+	}
+	if r.Result != nil {
+		resolved.Result = replaceODV(r.Result, value).(map[string]interface{})
+	}
+	return &resolved
+}
+
+// replaceODV returns a copy of a decoded YAML value with $ODV placeholders
+// replaced, keeping the ODV's type where the placeholder is the whole value
+func replaceODV(v interface{}, value interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		if t == "$ODV" {
+			return value
+		}
+		return strings.ReplaceAll(t, "$ODV", fmt.Sprint(value))
+	case map[string]interface{}:
+		replaced := make(map[string]interface{}, len(t))
+		for key, item := range t {
+			replaced[key] = replaceODV(item, value)
+		}
+		return replaced
+	case []interface{}:
+		replaced := make([]interface{}, len(t))
+		for i, item := range t {
+			replaced[i] = replaceODV(item, value)
+		}
+		return replaced
+	}
+	return v
+}
+
+// queryMapping represents a pattern-to-query mapping. An empty Query leaves
+// matching policies unchanged for manual review.
+type queryMapping struct {
+	Pattern string
+	Query   string
+}
+
+// queryMappings returns the comprehensive query mappings
+func queryMappings() []queryMapping {
+	auditFlag := func(flag string) string {
+		return auditControlCheck{Setting: auditFlags, Tokens: []string{flag}}.Query()
+	}
+	return []queryMapping{
+		// Audit-related policies. No osquery table reads ACLs, so the ACL
+		// policies are left for manual review.
+		{`.*audit.*(files|folder).*not.*contain.*access.*control.*lists.*`, ""},
+
+		{`.*enable.*security.*auditing.*`,
+			selectFrom("launchd", eq("label", "com.apple.auditd"), notEq("disabled", "1")) + ";"},
+
+		{`.*audit.*capacity.*warning.*`,
+			auditControlCheck{Setting: auditMinFree, Value: "25"}.Query()},
+
+		{`.*shut.*down.*upon.*audit.*failure.*`,
+			auditControlCheck{Setting: auditPolicy, Tokens: []string{"ahlt"}}.Query()},
+
+		{`.*audit.*log.*files.*group.*wheel.*`,
+			auditDirectoryCheck(false, fileGroup, 0).Query()},
+
+		{`.*audit.*log.*files.*mode.*440.*`,
+			auditDirectoryCheck(false, fileMode, 0440).Query()},
+
+		{`.*audit.*log.*files.*owned.*root.*`,
+			auditDirectoryCheck(false, fileOwner, 0).Query()},
+
+		{`.*audit.*folders.*group.*wheel.*`,
+			auditDirectoryCheck(true, fileGroup, 0).Query()},
+
+		{`.*audit.*folders.*owned.*root.*`,
+			auditDirectoryCheck(true, fileOwner, 0).Query()},
+
+		{`.*audit.*folders.*mode.*700.*`,
+			auditDirectoryCheck(true, fileMode, 0700).Query()},
+
+		// Audit event policies
+		{`.*audit.*authorization.*authentication.*events.*`, auditFlag("aa")},
+
+		{`.*audit.*administrative.*action.*events.*`, auditFlag("ad")},
+
+		{`.*audit.*failed.*program.*execution.*`, auditFlag("-ex")},
+
+		{`.*audit.*deletions.*object.*attributes.*`, auditFlag("-fd")},
+
+		{`.*audit.*failed.*change.*object.*attributes.*`, auditFlag("-fm")},
+
+		{`.*audit.*failed.*read.*actions.*`, auditFlag("-fr")},
+
+		{`.*audit.*failed.*write.*actions.*`, auditFlag("-fw")},
+
+		{`.*audit.*log.*in.*log.*out.*events.*`, auditFlag("lo")},
+
+		// FileVault policies
+		{`.*filevault.*enabled.*`,
+			postureQuery(fileVaultOn)},
+
+		{`.*filevault.*auto.*login.*disabled.*`,
+			managedPolicyQuery("com.apple.loginwindow", "DisableFDEAutoLogin", true)},
+
+		// Firewall policies
+		{`.*firewall.*enabled.*`,
+			postureQuery(firewallEnabled)},
+
+		{`.*firewall.*stealth.*mode.*`,
+			postureQuery(firewallStealth)},
+
+		{`.*firewall.*logging.*`,
+			postureQuery(firewallLogging)},
+
+		// System Integrity Protection and Gatekeeper
+		{`.*system.*integrity.*protection.*`,
+			postureQuery(sipEnabled)},
+
+		{`.*gatekeeper.*`,
+			postureQuery(gatekeeperEnabled)},
+
+		// Screen saver policies
+		{`.*screen.*saver.*password.*required.*`,
+			managedPolicyQuery("com.apple.screensaver", "askForPassword", true)},
+
+		{`.*screen.*saver.*timeout.*`,
+			passIfExists(selectFrom("managed_policies", eq("domain", "com.apple.screensaver"), eq("name", "idleTime"), "CAST(value AS INTEGER) BETWEEN 1 AND 1200"))},
+
+		// Location services
+		{`.*location.*services.*disabled.*`,
+			managedPolicyQuery("com.apple.locationd", "LocationServicesEnabled", false)},
+
+		// Sharing services
+		{`.*disable.*screen.*sharing.*`, sharingQuery("system_settings_screen_sharing_disable")},
+
+		{`.*disable.*(smb|file).*sharing.*`, sharingQuery("system_settings_smbd_disable")},
+
+		{`.*disable.*(ssh.*server|remote.*login).*`, sharingQuery("system_settings_ssh_disable")},
+
+		{`.*disable.*remote.*management.*`, sharingQuery("system_settings_remote_management_disable")},
+
+		{`.*disable.*remote.*apple.*events.*`, sharingQuery("system_settings_rae_disable")},
+
+		{`.*disable.*printer.*sharing.*`, sharingQuery("system_settings_printer_sharing_disable")},
+
+		{`.*disable.*internet.*sharing.*`, sharingQuery("system_settings_internet_sharing_disable")},
+
+		{`.*disable.*content.*caching.*`, sharingQuery("system_settings_content_caching_disable")},
+
+		{`.*disable.*bluetooth.*sharing.*`, sharingQuery("system_settings_bluetooth_sharing_disable")},
+
+		{`.*disable.*media.*sharing.*`, sharingQuery("system_settings_media_sharing_disabled")},
+
+		// Bluetooth
+		{`.*bluetooth.*disabled.*`,
+			managedPolicyQuery("com.apple.MCXBluetooth", "DisableBluetooth", true)},
+
+		// Guest account
+		{`.*guest.*account.*disabled.*`,
+			managedPolicyQuery("com.apple.MCX", "DisableGuestAccount", true)},
+
+		// Software updates
+		{`.*software.*update.*automatic.*`,
+			selectFrom("software_update", eq("software_update_required", "0")) + ";"},
+
+		// Any other policy is left for manual review; a managed profile
+		// domain alone does not show the setting a rule requires
+		{`.*`, ""},
+	}
+}
+
+// QueryForPolicyName returns the query of the first query mapping whose
+// pattern matches a policy name, ignoring case, normalized as the converter
+// writes it. It reports false when the matching mapping leaves the policy for
+// manual review.
+func QueryForPolicyName(name string) (string, bool) {
+	for _, mapping := range queryMappings() {
+		pattern, err := regexp.Compile("(?i)" + mapping.Pattern)
+		if err != nil || !pattern.MatchString(name) {
+			continue
+		}
+		if mapping.Query == "" {
+			return "", false
+		}
+		return normalizeQuery(mapping.Query), true
+	}
+	return "", false
+}
+
+// loadYAML loads a YAML file into the given interface
+func loadYAML(filename string, v interface{}) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// loadYAMLFS loads a YAML file from a filesystem into the given interface
+func loadYAMLFS(fsys fs.FS, name string, v interface{}) error {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// saveYAML saves the given interface to a YAML file
+func saveYAML(filename string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, data)
+}
+
+// LoadPolicies loads every Fleet policy document from a multi-document YAML file
+func LoadPolicies(filename string) ([]*FleetPolicy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []*FleetPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var policy FleetPolicy
+		err := decoder.Decode(&policy)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse policies in %s: %w", filename, err)
+		}
+		if policy.Kind == "policy" {
+			policies = append(policies, &policy)
+		}
+	}
+	return policies, nil
+}
+
+// marshalYAML marshals the given interface to YAML bytes
+func marshalYAML(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// joinDocuments joins marshaled YAML documents with separators between them,
+// without a trailing empty document
+func joinDocuments(docs [][]byte) []byte {
+	return bytes.Join(docs, []byte("---\n"))
+}
+
+// contentHash returns the sha256 of generated content for output headers
+func contentHash(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// WriteFileAtomic writes data to a temporary file next to filename and renames
+// it into place, so a crash never leaves a truncated file behind. A file that
+// already holds exactly data is left untouched.
+func WriteFileAtomic(filename string, data []byte) error {
+	if existing, err := os.ReadFile(filename); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,230 @@
+package mscpfleet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// convertCheckToQuery converts a check script to a Fleet query
+func convertCheckToQuery(checkScript, ruleID string) string {
+	// Extract the osascript command and convert to SQL-like query
+	if strings.Contains(checkScript, "osascript") && strings.Contains(checkScript, "objectForKey") {
+		// The value each key must have is unknown when the script reads several
+		if len(checkKeyPattern.FindAllString(checkScript, -1)) > 1 {
+			return unmappedQuery
+		}
+
+		// Extract suite name and key using regex
+		suiteMatch := checkSuitePattern.FindStringSubmatch(checkScript)
+		keyMatch := checkKeyPattern.FindStringSubmatch(checkScript)
+
+		if len(suiteMatch) > 1 && len(keyMatch) > 1 {
+			suiteName := suiteMatch[1]
+			keyName := keyMatch[1]
+			return managedPolicyQuery(suiteName, keyName, true)
+		}
+	}
+
+	// For file-based checks, only the audit log rules have a known attribute
+	// to compare; selecting files by path alone passes on every host
+	if strings.Contains(checkScript, "/etc/") || strings.Contains(checkScript, "/var/") {
+		if strings.Contains(ruleID, "audit") {
+			if query := auditFileQuery(checkScript, ruleID); query != "" {
+				return query
+			}
+		}
+		return unmappedQuery
+	}
+
+	// For launchctl checks
+	if strings.Contains(checkScript, "launchctl") {
+		if strings.Contains(ruleID, "audit") {
+			return selectFrom("launchd", eq("name", "com.apple.auditd")) + ";"
+		}
+		return unmappedQuery
+	}
+
+	// For software update checks
+	if strings.Contains(checkScript, "softwareupdate") {
+		return selectFrom("software_update", eq("software_update_required", "0")) + ";"
+	}
+
+	// For specific rule types
+	if strings.Contains(ruleID, "firewall") {
+		return postureQuery(firewallEnabled)
+	} else if strings.Contains(ruleID, "gatekeeper") {
+		return postureQuery(gatekeeperEnabled)
+	} else if strings.Contains(ruleID, "filevault") {
+		return postureQuery(fileVaultOn)
+	}
+
+	// Default fallback - use a simple check that will always pass
+	return unmappedQuery
+}
+
+// checkGenerators build exact queries for check scripts that read a known
+// system setting. The first generator that understands a rule's check is used
+// before falling back to convertCheckToQuery.
+var checkGenerators = []func(rule *Rule) (string, bool){
+	auditControlQuery,
+	fileAttributeQuery,
+}
+
+// generateCheckQuery returns the query of the first generator that understands the rule's check
+func generateCheckQuery(rule *Rule) (string, bool) {
+	for _, generate := range checkGenerators {
+		if query, ok := generate(rule); ok {
+			return query, true
+		}
+	}
+	return "", false
+}
+
+// unmappedQuery is the fallback for checks no mapping understands
+const unmappedQuery = "SELECT 1;"
+
+// isUnmappedQuery reports whether a query is the always-passing fallback
+func isUnmappedQuery(query string) bool {
+	return normalizeQuery(unscopeQuery(query)) == unmappedQuery
+}
+
+// auditFileQuery builds queries for the audit log file and folder rules.
+// Ownership must hold for every file, so it cannot be expressed by selecting
+// matching files. ACLs are not extended attributes and no osquery table reads
+// them, so the ACL rules are left unmapped for manual review.
+func auditFileQuery(checkScript, ruleID string) string {
+	folder := strings.Contains(ruleID, "folder")
+	switch {
+	case strings.Contains(ruleID, "acls"):
+		return unmappedQuery
+	case strings.Contains(ruleID, "owner"):
+		return auditDirectoryCheck(folder, fileOwner, 0).Query()
+	case strings.Contains(ruleID, "group"):
+		return auditDirectoryCheck(folder, fileGroup, 0).Query()
+	}
+	return ""
+}
+
+// auditDirectoryCheck returns a file attribute check of the audit log folder
+// or of every file in it. Like mSCP's mode check, the mode of the files skips
+// the current symlink to the active log.
+func auditDirectoryCheck(folder bool, attribute string, value int) fileAttributeCheck {
+	if folder {
+		return fileAttributeCheck{Path: auditDirectory, Scope: fileScopeSelf, Type: "directory", Attribute: attribute, Value: value}
+	}
+	check := fileAttributeCheck{Path: auditDirectory, Scope: fileScopeEntries, Attribute: attribute, Value: value}
+	if attribute == fileMode {
+		check.Exclude = []string{"current"}
+	}
+	return check
+}
+
+// findYAMLFiles finds all YAML files matching the pattern
+func findYAMLFiles(dir string, pattern string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, pattern) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// baselineNameFromPath extracts the baseline name from a file path
+func baselineNameFromPath(filePath string) string {
+	base := filepath.Base(filePath)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext)
+}
+
+// policyNamePrefix starts the name of every generated policy
+const policyNamePrefix = "macOS Security - "
+
+// PolicyOptions controls how policies are rendered from rules. The zero
+// value renders descriptions and resolutions as Markdown and checks the
+// device state of posture rules.
+type PolicyOptions struct {
+	// TextFormat is TextMarkdown or TextPlain
+	TextFormat string
+	// SectionPrefix puts the baseline section in front of the rule title in policy names
+	SectionPrefix bool
+	// Posture is PostureState, PostureProfile or PostureBoth; empty means PostureState
+	Posture string
+}
+
+// CreateFleetPolicy creates a Fleet policy from a rule definition
+func CreateFleetPolicy(rule *Rule, baselineName string, opts PolicyOptions) *FleetPolicy {
+	if rule == nil {
+		return nil
+	}
+
+	// Extract CIS benchmark information if available
+	var cisBenchmark, cisLevel string
+	if references, ok := rule.References["cis"].(map[string]interface{}); ok {
+		if benchmark, ok := references["benchmark"].([]interface{}); ok && len(benchmark) > 0 {
+			cisBenchmark = benchmark[0].(string)
+		}
+		if level, ok := references["level"].([]interface{}); ok && len(level) > 0 {
+			cisLevel = level[0].(string)
+		}
+	}
+
+	// Create tags
+	tags := []string{"compliance", "macOS_Security_Compliance"}
+	if cisBenchmark != "" {
+		tags = append(tags, fmt.Sprintf("CIS_%s", cisBenchmark))
+	}
+	if cisLevel != "" {
+		tags = append(tags, fmt.Sprintf("CIS_Level%s", cisLevel))
+	}
+
+	// Add baseline-specific tag
+	baselineTag := strings.ReplaceAll(baselineName, "_", "_")
+	baselineTag = strings.ReplaceAll(baselineTag, "-", "_")
+	tags = append(tags, baselineTag)
+
+	// Render description and resolution text from AsciiDoc
+	description := renderText(rule.Discussion, opts.TextFormat)
+	resolution := renderText(rule.Fix, opts.TextFormat)
+
+	// Compose the query from the rule's profile keys and device state,
+	// falling back to converting the check script
+	var query string
+	if groups := combinePosture(rulePredicates(rule), postureGroups(rule), opts.Posture); len(groups) > 0 {
+		query = composeQuery(groups)
+		if len(groups) > 1 || len(groups[0]) > 1 {
+			description = strings.TrimSpace(description + "\n\n" + describePredicates(groups))
+		}
+	} else if generated, ok := generateCheckQuery(rule); ok {
+		query = generated
+	} else {
+		query = convertCheckToQuery(rule.Check, rule.ID)
+	}
+
+	policyName := rule.Title
+	if policyName == "" {
+		policyName = rule.ID
+	}
+
+	return &FleetPolicy{
+		APIVersion: "v1",
+		Kind:       "policy",
+		Spec: PolicySpec{
+			Name:         policyNamePrefix + policyName,
+			Platforms:    "macOS",
+			Platform:     "darwin",
+			Description:  description,
+			Resolution:   resolution,
+			Query:        normalizeQuery(query),
+			Purpose:      "Informational",
+			Tags:         tags,
+			Contributors: "macos_security_compliance_project",
+		},
+	}
+}
@@ -0,0 +1,212 @@
+package mscpfleet
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BaselineResult holds the Fleet policies generated for one baseline or tailoring
+type BaselineResult struct {
+	Name     string
+	Title    string
+	Policies []FleetPolicy
+	// Sections holds the baseline section of each policy, parallel to Policies
+	Sections []string
+	// RuleIDs holds the rule each policy was generated from, parallel to Policies
+	RuleIDs []string
+	Info    BaselineInfo
+
+	// converted holds what each policy was built from, parallel to Policies,
+	// and names the template that named them; MergeBaselines rebuilds merged
+	// policies from both
+	converted []*ConvertedRule
+	names     *NameTemplate
+}
+
+// Result is the outcome of converting an mSCP source
+type Result struct {
+	SourceVersion string
+	Baselines     []*BaselineResult
+	Labels        []FleetLabel
+	Report        *ComplianceReport
+	Diagnostics   *Diagnostics
+	// Failed names the baselines and tailorings that could not be converted
+	Failed []string
+}
+
+// Policies returns the policies of every baseline in order
+func (r *Result) Policies() []FleetPolicy {
+	var policies []FleetPolicy
+	for _, baseline := range r.Baselines {
+		policies = append(policies, baseline.Policies...)
+	}
+	return policies
+}
+
+// generatedHeader is the header line that marks files written by this tool
+const generatedHeader = "# Generated from macOS Security Compliance Project\n"
+
+// IsGenerated reports whether the leading comment of a file carries the
+// header that MarshalPolicies, MarshalLabels and MarshalGitOpsTeam write
+func IsGenerated(data []byte) bool {
+	for len(data) > 0 && data[0] == '#' {
+		line, rest, _ := bytes.Cut(data, []byte("\n"))
+		if string(line)+"\n" == generatedHeader {
+			return true
+		}
+		data = rest
+	}
+	return false
+}
+
+// MarshalPolicies renders a baseline as a multi-document Fleet policy file
+// with a header naming the baseline, the mSCP source, the content hash and
+// the baseline's provenance
+func MarshalPolicies(baseline *BaselineResult, sourceVersion string) ([]byte, error) {
+	docs := make([][]byte, 0, len(baseline.Policies))
+	for i := range baseline.Policies {
+		data, err := marshalYAML(&baseline.Policies[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal policy %q: %w", baseline.Policies[i].Spec.Name, err)
+		}
+		docs = append(docs, data)
+	}
+	body := joinDocuments(docs)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Fleet policies for %s\n", baseline.Title)
+	sb.WriteString(generatedHeader)
+	fmt.Fprintf(&sb, "# mSCP source: %s\n", sourceVersion)
+	fmt.Fprintf(&sb, "# Content hash: %s\n", contentHash(body))
+	for _, line := range baseline.Info.CommentLines() {
+		sb.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+	sb.WriteString("\n")
+	sb.Write(body)
+	return []byte(sb.String()), nil
+}
+
+// MarshalLabels renders the release scoping labels as a multi-document Fleet label file
+func MarshalLabels(labels []FleetLabel, sourceVersion string) ([]byte, error) {
+	docs := make([][]byte, 0, len(labels))
+	for i := range labels {
+		data, err := marshalYAML(&labels[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal label %s: %w", labels[i].Spec.Name, err)
+		}
+		docs = append(docs, data)
+	}
+	body := joinDocuments(docs)
+
+	var sb strings.Builder
+	sb.WriteString("# Fleet labels for macOS release scoping\n")
+	sb.WriteString(generatedHeader)
+	fmt.Fprintf(&sb, "# mSCP source: %s\n", sourceVersion)
+	fmt.Fprintf(&sb, "# Content hash: %s\n\n", contentHash(body))
+	sb.Write(body)
+	return []byte(sb.String()), nil
+}
+
+// WriteOptions controls how WriteOutput lays out policy files
+type WriteOptions struct {
+	// SplitSections writes one file per baseline section instead of one per baseline
+	SplitSections bool
+	// GitOpsTeamFile, when set, is where a Fleet GitOps team file holding
+	// every policy is written; GitOpsTeam names the team
+	GitOpsTeamFile string
+	GitOpsTeam     string
+}
+
+// PolicyFile is the content of one policy file and the baseline it belongs to
+type PolicyFile struct {
+	Baseline string
+	*BaselineResult
+}
+
+// FileName returns the name the policy file is written under
+func (pf PolicyFile) FileName() string {
+	return pf.Name + "-fleet-policies.yml"
+}
+
+// PolicyFiles returns the policy files of a result: one per baseline, or
+// with SplitSections one <baseline>-<section>-fleet-policies.yml per section
+func (r *Result) PolicyFiles(opts WriteOptions) []PolicyFile {
+	var files []PolicyFile
+	for _, baseline := range r.Baselines {
+		if !opts.SplitSections {
+			files = append(files, PolicyFile{Baseline: baseline.Name, BaselineResult: baseline})
+			continue
+		}
+		for _, part := range baseline.SplitSections() {
+			files = append(files, PolicyFile{Baseline: baseline.Name, BaselineResult: part})
+		}
+	}
+	return files
+}
+
+// WriteOutput writes the policy files of a result, fleet-labels.yml when
+// policies are scoped with labels, and compliance-report.md to dir, plus the
+// GitOps team file when one is requested, recording written counts in the
+// diagnostics and logging each file through their logger
+func WriteOutput(dir string, result *Result, opts WriteOptions) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	logger := discardLogger()
+	if result.Diagnostics != nil {
+		logger = result.Diagnostics.Logger()
+	}
+
+	for _, file := range result.PolicyFiles(opts) {
+		data, err := MarshalPolicies(file.BaselineResult, result.SourceVersion)
+		if err != nil {
+			return fmt.Errorf("baseline %s: %w", file.Name, err)
+		}
+		outputFile := filepath.Join(dir, file.FileName())
+		if err := WriteFileAtomic(outputFile, data); err != nil {
+			return fmt.Errorf("failed to write output file %s: %w", outputFile, err)
+		}
+		if result.Diagnostics != nil {
+			result.Diagnostics.Baseline(file.Baseline).Written += len(file.Policies)
+		}
+		logger.Info("wrote baseline", "baseline", file.Name, "policies", len(file.Policies), "file", outputFile)
+	}
+
+	if opts.GitOpsTeamFile != "" {
+		team := opts.GitOpsTeam
+		if team == "" {
+			team = baselineNameFromPath(opts.GitOpsTeamFile)
+		}
+		data, err := MarshalGitOpsTeam(team, result.Baselines, result.SourceVersion)
+		if err != nil {
+			return err
+		}
+		if err := WriteFileAtomic(opts.GitOpsTeamFile, data); err != nil {
+			return fmt.Errorf("failed to write GitOps team file %s: %w", opts.GitOpsTeamFile, err)
+		}
+		logger.Info("wrote GitOps team file", "team", team, "file", opts.GitOpsTeamFile, "policies", len(result.Policies()))
+	}
+
+	if len(result.Labels) > 0 {
+		data, err := MarshalLabels(result.Labels, result.SourceVersion)
+		if err != nil {
+			return err
+		}
+		labelsFile := filepath.Join(dir, "fleet-labels.yml")
+		if err := WriteFileAtomic(labelsFile, data); err != nil {
+			return fmt.Errorf("failed to write labels file %s: %w", labelsFile, err)
+		}
+		logger.Info("wrote release scoping labels", "file", labelsFile, "labels", len(result.Labels))
+	}
+
+	if result.Report != nil {
+		reportFile := filepath.Join(dir, "compliance-report.md")
+		if err := result.Report.Write(reportFile); err != nil {
+			return fmt.Errorf("failed to write compliance report %s: %w", reportFile, err)
+		}
+	}
+	return nil
+}
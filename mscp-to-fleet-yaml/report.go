@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// ReportOptions holds the options for the report command
+type ReportOptions struct {
+	ConvertOptions
+	OutputFile string
+}
+
+// RunReport converts the baselines in memory and writes only the compliance
+// report, to a file or to stdout
+func RunReport(opts ReportOptions) error {
+	converter, _, err := newConverter(&opts.ConvertOptions)
+	if err != nil {
+		return err
+	}
+	result, err := converter.ConvertAllBaselines()
+	if err != nil {
+		return err
+	}
+
+	report := []byte(result.Report.Markdown())
+	if opts.OutputFile == "" {
+		_, err = os.Stdout.Write(report)
+		return err
+	}
+	if err := mscpfleet.WriteFileAtomic(opts.OutputFile, report); err != nil {
+		return fmt.Errorf("failed to write compliance report %s: %w", opts.OutputFile, err)
+	}
+	slog.Info("report written", "file", opts.OutputFile, "baselines", len(result.Baselines))
+	return nil
+}
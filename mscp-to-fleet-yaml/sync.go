@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// SyncOptions holds the options for the sync command
+type SyncOptions struct {
+	ConvertOptions
+	DryRun bool
+	Prune  bool
+}
+
+// RunSync brings an output directory, such as a GitOps repository, in line
+// with the mSCP source: policy files are created or updated, and with Prune
+// policy files this tool wrote that are no longer generated are removed.
+// Nothing is pruned when a baseline failed to convert or only some baselines
+// are selected. Each change is printed to stdout; with DryRun nothing is
+// written.
+func RunSync(opts SyncOptions) error {
+	converter, source, err := newConverter(&opts.ConvertOptions)
+	if err != nil {
+		return err
+	}
+	outputDir := defaultOutputDir(source, opts.OutputDir)
+
+	result, err := convertAll(converter, &opts.ConvertOptions)
+	if err != nil {
+		return err
+	}
+
+	existing, err := filepath.Glob(filepath.Join(outputDir, "*-fleet-policies.yml"))
+	if err != nil {
+		return fmt.Errorf("failed to find YAML files: %w", err)
+	}
+	stale := map[string]bool{}
+	for _, file := range existing {
+		stale[file] = true
+	}
+
+	created, updated, unchanged := 0, 0, 0
+	for _, policyFile := range result.PolicyFiles(opts.writeOptions()) {
+		file := filepath.Join(outputDir, policyFile.FileName())
+		delete(stale, file)
+		data, err := mscpfleet.MarshalPolicies(policyFile.BaselineResult, result.SourceVersion)
+		if err != nil {
+			return err
+		}
+		current, err := os.ReadFile(file)
+		switch {
+		case err != nil:
+			fmt.Printf("+ %s (%d policies)\n", file, len(policyFile.Policies))
+			created++
+		case !bytes.Equal(current, data):
+			fmt.Printf("~ %s (%d policies)\n", file, len(policyFile.Policies))
+			updated++
+		default:
+			unchanged++
+		}
+	}
+
+	var removed []string
+	switch {
+	case !opts.Prune:
+	case len(result.Failed) > 0:
+		opts.Summary.Warn(mscpfleet.Warning{Message: "not pruning, baselines failed to convert: " + strings.Join(result.Failed, ", ")})
+	case len(opts.Baselines) > 0:
+		opts.Summary.Warn(mscpfleet.Warning{Message: "not pruning, -baselines converts only part of the source"})
+	default:
+		for _, file := range existing {
+			if !stale[file] {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read policy file %s: %w", file, err)
+			}
+			if !mscpfleet.IsGenerated(data) {
+				slog.Info("keeping policy file not generated by this tool", "file", file)
+				continue
+			}
+			fmt.Printf("- %s\n", file)
+			removed = append(removed, file)
+		}
+	}
+
+	opts.Summary.Count("created", created)
+	opts.Summary.Count("updated", updated)
+	opts.Summary.Count("unchanged", unchanged)
+	opts.Summary.Count("removed", len(removed))
+	// A strict run that fails leaves the output directory untouched
+	if opts.Strict {
+		if err := converter.StrictCheck(); err != nil {
+			return err
+		}
+	}
+	if opts.DryRun {
+		slog.Info("dry run, nothing written", "output", outputDir, "created", created, "updated", updated,
+			"unchanged", unchanged, "removed", len(removed))
+		return nil
+	}
+
+	if err := mscpfleet.WriteOutput(outputDir, result, opts.writeOptions()); err != nil {
+		return err
+	}
+	for _, file := range removed {
+		if err := os.Remove(file); err != nil {
+			return fmt.Errorf("failed to remove stale policy file %s: %w", file, err)
+		}
+	}
+
+	slog.Info("sync complete", "output", outputDir, "created", created, "updated", updated,
+		"unchanged", unchanged, "removed", len(removed))
+	return nil
+}
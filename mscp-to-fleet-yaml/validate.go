@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"mscp-to-fleet-yaml/mscpfleet"
+)
+
+// ValidateOptions holds the options for the validate command
+type ValidateOptions struct {
+	Paths   []string
+	Summary *RunSummary
+}
+
+// RunValidate checks existing policy files for missing fields, queries that
+// cannot express failure and policy names used more than once in a file
+func RunValidate(opts ValidateOptions) error {
+	if opts.Summary == nil {
+		opts.Summary = NewRunSummary("validate")
+	}
+	paths := opts.Paths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return mscpfleet.InputError(err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*-fleet-policies.yml"))
+		if err != nil {
+			return fmt.Errorf("failed to find YAML files: %w", err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%w: no policy files found in %v", mscpfleet.ErrInput, paths)
+	}
+
+	totalIssues := 0
+	for _, file := range files {
+		policies, err := mscpfleet.LoadPolicies(file)
+		if err != nil {
+			return mscpfleet.InputError(err)
+		}
+
+		var issues []mscpfleet.LintIssue
+		seen := map[string]bool{}
+		for _, policy := range policies {
+			issues = append(issues, mscpfleet.ValidatePolicy(policy)...)
+			if seen[policy.Spec.Name] {
+				issues = append(issues, mscpfleet.LintIssue{Policy: policy.Spec.Name, Rule: "duplicate-name", Message: "is defined more than once"})
+			}
+			seen[policy.Spec.Name] = true
+		}
+
+		slog.Info("validated file", "file", file, "policies", len(policies), "issues", len(issues))
+		for _, issue := range issues {
+			opts.Summary.Warn(mscpfleet.Warning{Message: fmt.Sprintf("[%s] %s", issue.Rule, issue.Message), Policy: issue.Policy, File: file})
+		}
+		opts.Summary.Count("files", 1)
+		opts.Summary.Count("policies", len(policies))
+		totalIssues += len(issues)
+	}
+
+	opts.Summary.Count("issues", totalIssues)
+	slog.Info("validation complete", "files", len(files), "issues", totalIssues)
+	if totalIssues > 0 {
+		return fmt.Errorf("%w: %d problems in %d files", mscpfleet.ErrValidation, totalIssues, len(files))
+	}
+	return nil
+}